@@ -6,7 +6,9 @@ import (
 	"os"
 //	"github.com/pborman/getopt"
 
+	"github.com/takahawk/shadownet/logger"
 	"github.com/takahawk/shadownet/pipelines"
+	"github.com/takahawk/shadownet/resolvers"
 	// "github.com/takahawk/shadownet/downloaders"
 	// "github.com/takahawk/shadownet/transformers"
 	// "github.com/takahawk/shadownet/uploaders"
@@ -59,7 +61,8 @@ func main() {
 	// fmt.Printf("URL: %+v\n", shadowURL)
 	shadowURL := "ZG93bl9wYXN0ZWJpbjpjRzU1Y1Zaa2RuST0=.dHJhbnNfYmFzZTY0Og==.dHJhbnNfYWVzOmRHaGxjbVZwYzI1dmMzQnZiMjUwYUdWeVpXbHpibTl6Y0c5dmJuUm9aWEk9LFlXSmpaR1ZtWjJoaFltTmtaV1puYUE9PQ=="
 
-	pipeline, err := pipelines.NewDownloadPipelineByURL(shadowURL)
+	logger := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	pipeline, err := pipelines.NewDownloadPipelineByURL(logger, resolvers.NewBuiltinResolver(logger), shadowURL)
 	if err != nil {
 		fmt.Printf("Error: %+v\n", err)
 		os.Exit(-1)
@@ -1,15 +1,25 @@
 package main
 
 import (
+	"os"
+
 	"github.com/takahawk/shadownet/gateway"
 	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/resolvers"
 	"github.com/takahawk/shadownet/storages"
-	"github.com/takahawk/shadownet/uploaders"
 )
 
 const DefaultDatabaseFilename = "shadownet.db"
+const DefaultPluginsDirectory = "plugins"
+const DefaultWasmDirectory = "wasm"
+const DefaultBlobsDirectory = "blobs"
 const ShadowNetPort = 10176
 
+// ExternalURLEnv is environment variable with base URL gateway is reachable
+// at by clients (i.e. behind reverse proxy). Host of request is used if it is
+// not set
+const ExternalURLEnv = "SHADOWNET_EXTERNAL_URL"
+
 func main() {
 	// TODO: set port through options
 
@@ -20,10 +30,33 @@ func main() {
 		return
 	}
 
-	data := []byte("<html><body><b>Bold text</b><br><i>Italic text</i><br>Plain text</body></html")
-	uploader := uploaders.NewDropboxUploader(logger, "sl.BmDvCi4cSKqTNJTN-QSdi-SgjAMn_oIP3pa5D_NhjcMkcdj69MPAYs7oAP6jbmPwklQIyhYce-vUpZZ5IrD3GBzScR6yXwCvdJ_SwDRbxJ4haBHaenf6DDn2a2hDH1N4AeZBEpeoRuekc0M")
+	// built-in components take priority over wasm and plugins ones
+	resolverChain := []resolvers.Resolver{resolvers.NewBuiltinResolverWithFileDirectory(logger, DefaultBlobsDirectory)}
+	if _, err := os.Stat(DefaultWasmDirectory); err == nil {
+		wasmResolver, err := resolvers.NewWasmResolver(logger, DefaultWasmDirectory)
+		if err != nil {
+			return
+		}
+		defer wasmResolver.Close()
+		resolverChain = append(resolverChain, wasmResolver)
+	}
+	if _, err := os.Stat(DefaultPluginsDirectory); err == nil {
+		pluginResolver, err := resolvers.NewPluginResolver(logger, DefaultPluginsDirectory)
+		if err != nil {
+			return
+		}
+		defer pluginResolver.Close()
+		resolverChain = append(resolverChain, pluginResolver)
+	}
+	resolver := resolvers.NewChainResolver(resolverChain...)
 
-	uploader.Upload(data)
-	gateway := gateway.NewShadowGateway(logger, storage)
-	gateway.Start(ShadowNetPort)
+	shadowGateway := gateway.NewShadowGateway(logger, storage, resolver)
+	if externalURL := os.Getenv(ExternalURLEnv); externalURL != "" {
+		shadowGateway, err = gateway.NewShadowGatewayWithExternalURL(logger, storage, resolver, externalURL)
+		if err != nil {
+			logger.Errorf("%+v", err)
+			return
+		}
+	}
+	shadowGateway.Start(ShadowNetPort)
 }
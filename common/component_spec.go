@@ -0,0 +1,57 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// EncodeComponentSpec packs component name and its params into single byte
+// slice, so that component can be passed as a parameter of another one
+// (e.g. downloader of composite downloader). Name and every param are written
+// prefixed with their length in uvarint form
+func EncodeComponentSpec(name string, params [][]byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(binary.AppendUvarint(nil, uint64(len(name))))
+	buf.WriteString(name)
+	for _, param := range params {
+		buf.Write(binary.AppendUvarint(nil, uint64(len(param))))
+		buf.Write(param)
+	}
+	return buf.Bytes()
+}
+
+// DecodeComponentSpec unpacks component name and params encoded with
+// EncodeComponentSpec
+func DecodeComponentSpec(data []byte) (name string, params [][]byte, err error) {
+	r := bytes.NewReader(data)
+	nameBytes, err := readLengthPrefixed(r)
+	if err != nil {
+		return "", nil, err
+	}
+	for r.Len() > 0 {
+		param, err := readLengthPrefixed(r)
+		if err != nil {
+			return "", nil, err
+		}
+		params = append(params, param)
+	}
+	return string(nameBytes), params, nil
+}
+
+func readLengthPrefixed(r *bytes.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, errors.New("malformed component spec")
+	}
+	if length > uint64(r.Len()) {
+		return nil, errors.New("malformed component spec")
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParamType is type of value component param holds. Params are always passed
+// as bytes, type tells how they are interpreted
+type ParamType string
+
+const (
+	// ParamTypeString is UTF-8 text (URLs, tokens, names etc.)
+	ParamTypeString ParamType = "string"
+	// ParamTypeBytes is arbitrary binary data (keys, hashes etc.)
+	ParamTypeBytes ParamType = "bytes"
+	// ParamTypeInt is integer in decimal form
+	ParamTypeInt ParamType = "int"
+	// ParamTypeComponent is another component encoded with
+	// EncodeComponentSpec
+	ParamTypeComponent ParamType = "component"
+)
+
+// ParamSchema describes single param of component
+type ParamSchema struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Description string    `json:"description,omitempty"`
+	// Lengths are allowed lengths of param in bytes. Any length is allowed if
+	// it is empty
+	Lengths []int `json:"lengths,omitempty"`
+	// Secret params (keys, tokens, passwords) should not be shown or logged
+	Secret bool `json:"secret,omitempty"`
+	// Optional params can be omitted. Only trailing params can be optional
+	Optional bool `json:"optional,omitempty"`
+	// Default is value used if optional param is omitted
+	Default string `json:"default,omitempty"`
+	// Variadic param can be repeated. Only the last param can be variadic
+	Variadic bool `json:"variadic,omitempty"`
+}
+
+// ComponentSchema describes component and params it takes
+type ComponentSchema struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Params      []ParamSchema `json:"params"`
+	// AnyParams means that params are not described and are not validated
+	// (i.e. plugin component without schema)
+	AnyParams bool `json:"anyParams,omitempty"`
+}
+
+// Describable is component that is able to describe itself
+type Describable interface {
+	// Schema returns schema of component
+	Schema() ComponentSchema
+}
+
+// Validate checks that params match schema
+func (cs ComponentSchema) Validate(params [][]byte) error {
+	if cs.AnyParams {
+		return nil
+	}
+
+	required := 0
+	variadic := false
+	for _, param := range cs.Params {
+		if !param.Optional {
+			required++
+		}
+		variadic = variadic || param.Variadic
+	}
+	if len(params) < required || (!variadic && len(params) > len(cs.Params)) {
+		return errors.New(fmt.Sprintf("%s: %s", cs.Name, cs.expectedParams()))
+	}
+
+	for i, value := range params {
+		param := cs.Params[min(i, len(cs.Params)-1)]
+		err := param.validate(value)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s: invalid param %s: %+v", cs.Name, param.Name, err))
+		}
+	}
+	return nil
+}
+
+// expectedParams returns description of params count in form of "there
+// should be 2 params: key and iv"
+func (cs ComponentSchema) expectedParams() string {
+	if len(cs.Params) == 0 {
+		return "there should be no params"
+	}
+	var names []string
+	for _, param := range cs.Params {
+		name := param.Name
+		if param.Variadic {
+			name += "..."
+		}
+		if param.Optional {
+			name = "[" + name + "]"
+		}
+		names = append(names, name)
+	}
+	return fmt.Sprintf("params should be: %s", strings.Join(names, ", "))
+}
+
+func (ps ParamSchema) validate(value []byte) error {
+	if len(ps.Lengths) != 0 {
+		valid := false
+		for _, length := range ps.Lengths {
+			valid = valid || len(value) == length
+		}
+		if !valid {
+			return errors.New(fmt.Sprintf("length should be one of %v bytes, got %d", ps.Lengths, len(value)))
+		}
+	}
+
+	switch ps.Type {
+	case ParamTypeString:
+		if !utf8.Valid(value) {
+			return errors.New("not a UTF-8 string")
+		}
+	case ParamTypeInt:
+		if _, err := strconv.Atoi(string(value)); err != nil {
+			return errors.New(fmt.Sprintf("not an integer: %s", value))
+		}
+	case ParamTypeComponent:
+		if _, _, err := DecodeComponentSpec(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package downloaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// ChunkedDownloaderName is chunked downloader component name
+const ChunkedDownloaderName = "chunked"
+
+// ChunkedDownloaderSchema describes chunked downloader and its params
+var ChunkedDownloaderSchema = common.ComponentSchema{
+	Name:        ChunkedDownloaderName,
+	Description: "Downloads data split into chunks by manifest",
+	Params: []common.ParamSchema{
+		{Name: "manifest", Type: common.ParamTypeComponent, Description: "downloader of manifest"},
+	},
+}
+
+// ChunkManifestVersion is version of chunk manifest format
+const ChunkManifestVersion = 1
+
+// ChunkedDownloadParallelism is maximum number of chunks downloaded at the
+// same time
+const ChunkedDownloadParallelism = 4
+
+// MaxChunkCount is maximum number of chunks manifest can list (64 GiB in
+// chunks of default size)
+const MaxChunkCount = 256 * 1024
+
+// MaxChunkManifestSize is maximum size of chunk manifest in bytes
+const MaxChunkManifestSize = 64 * 1024 * 1024
+
+// ChunkDownloaderNames are names of downloaders chunk manifest can refer to.
+// Manifest is fetched from third-party storage and is not trusted, so only
+// plain storage backends are allowed: composite downloaders would let it
+// make gateway recurse, file downloader reads local disk and plugin ones can
+// do anything
+var ChunkDownloaderNames = []string{
+	WebDownloaderName,
+	PastebinDownloaderName,
+	DropboxDownloaderName,
+	S3DownloaderName,
+	WebDAVDownloaderName,
+	GistDownloaderName,
+	GDriveDownloaderName,
+	IPFSDownloaderName,
+	DataDownloaderName,
+}
+
+// ChunkManifest lists chunks that content was split into. It is uploaded
+// along with chunks and ShadowNet URL points to it
+type ChunkManifest struct {
+	Version int                  `json:"version"`
+	Size    int64                `json:"size"`
+	Chunks  []ChunkManifestEntry `json:"chunks"`
+}
+
+// ChunkManifestEntry describes downloader of single chunk and its SHA-256
+// hash in hex form
+type ChunkManifestEntry struct {
+	Downloader string   `json:"downloader"`
+	Params     [][]byte `json:"params"`
+	Size       int64    `json:"size"`
+	SHA256     string   `json:"sha256"`
+}
+
+type chunkedDownloader struct {
+	logger             logger.Logger
+	resolve            ResolveFunc
+	manifestDownloader Downloader
+}
+
+type chunkResult struct {
+	data []byte
+	err  error
+}
+
+// NewChunkedDownloader returns downloader that gets chunk manifest using a
+// given downloader, then downloads chunks listed there in parallel, verifies
+// their hashes and joins them back together. Chunk downloaders are resolved
+// by their names with resolve function
+func NewChunkedDownloader(logger logger.Logger, resolve ResolveFunc, manifestDownloader Downloader) Downloader {
+	return &chunkedDownloader{
+		logger:             logger,
+		resolve:            resolve,
+		manifestDownloader: manifestDownloader,
+	}
+}
+
+// NewChunkedDownloaderWithParams returns chunked downloader for a given
+// params. The only param is manifest downloader encoded with
+// common.EncodeComponentSpec
+func NewChunkedDownloaderWithParams(logger logger.Logger, resolve ResolveFunc, params ...[]byte) (Downloader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be only 1 param: manifest downloader")
+	}
+	name, downloaderParams, err := common.DecodeComponentSpec(params[0])
+	if err != nil {
+		return nil, err
+	}
+	manifestDownloader, err := resolve(name, downloaderParams...)
+	if err != nil {
+		return nil, err
+	}
+	err = checkNestingDepth(manifestDownloader)
+	if err != nil {
+		return nil, err
+	}
+	return NewChunkedDownloader(logger, resolve, manifestDownloader), nil
+}
+
+// Name returns chunked downloader name. It is always ChunkedDownloaderName
+func (cd *chunkedDownloader) Name() string {
+	return ChunkedDownloaderName
+}
+
+// Schema returns schema of chunked downloader. It is always ChunkedDownloaderSchema
+func (cd *chunkedDownloader) Schema() common.ComponentSchema {
+	return ChunkedDownloaderSchema
+}
+
+// IsChunkDownloader tells whether chunk manifest can refer to downloader with
+// a given name
+func IsChunkDownloader(name string) bool {
+	for _, allowed := range ChunkDownloaderNames {
+		if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Params returns manifest downloader encoded with common.EncodeComponentSpec
+func (cd *chunkedDownloader) Params() [][]byte {
+	return [][]byte{common.EncodeComponentSpec(cd.manifestDownloader.Name(), cd.manifestDownloader.Params())}
+}
+
+// Download returns content joined from all chunks in a byte array
+func (cd *chunkedDownloader) Download() ([]byte, error) {
+	r, err := cd.DownloadStream()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// DownloadStream returns reader of content joined from all chunks. Chunks
+// are downloaded ahead in parallel, but at most ChunkedDownloadParallelism of
+// them are held in memory at once
+func (cd *chunkedDownloader) DownloadStream() (io.ReadCloser, error) {
+	manifest, err := cd.downloadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		done := make(chan struct{})
+		defer close(done)
+
+		results := make([]chan chunkResult, len(manifest.Chunks))
+		for i := range results {
+			results[i] = make(chan chunkResult, 1)
+		}
+		slots := make(chan struct{}, ChunkedDownloadParallelism)
+		go func() {
+			for i := range manifest.Chunks {
+				select {
+				case slots <- struct{}{}:
+				case <-done:
+					return
+				}
+				go func(i int) {
+					data, err := cd.downloadChunk(i, &manifest.Chunks[i])
+					results[i] <- chunkResult{data: data, err: err}
+				}(i)
+			}
+		}()
+
+		for i := range manifest.Chunks {
+			result := <-results[i]
+			<-slots
+			if result.err != nil {
+				pw.CloseWithError(result.err)
+				return
+			}
+			_, err := pw.Write(result.data)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	return pr, nil
+}
+
+func (cd *chunkedDownloader) depth() int {
+	return nestingDepth(cd.manifestDownloader) + 1
+}
+
+func (cd *chunkedDownloader) downloadManifest() (*ChunkManifest, error) {
+	data, err := cd.manifestDownloader.Download()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxChunkManifestSize {
+		return nil, errors.New(fmt.Sprintf("chunk manifest is bigger than %d bytes", MaxChunkManifestSize))
+	}
+
+	var manifest ChunkManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		cd.logger.Errorf("Error unmarshaling chunk manifest: %+v", err)
+		return nil, err
+	}
+	if manifest.Version != ChunkManifestVersion {
+		return nil, errors.New(fmt.Sprintf("unsupported chunk manifest version: %d", manifest.Version))
+	}
+	if len(manifest.Chunks) > MaxChunkCount {
+		return nil, errors.New(fmt.Sprintf("chunk manifest lists more than %d chunks", MaxChunkCount))
+	}
+	for i, entry := range manifest.Chunks {
+		if !IsChunkDownloader(entry.Downloader) {
+			return nil, errors.New(fmt.Sprintf("chunk %d can't be downloaded with %s", i, entry.Downloader))
+		}
+	}
+	cd.logger.Infof("Downloading %d bytes in %d chunks", manifest.Size, len(manifest.Chunks))
+	return &manifest, nil
+}
+
+func (cd *chunkedDownloader) downloadChunk(i int, entry *ChunkManifestEntry) ([]byte, error) {
+	downloader, err := cd.resolve(entry.Downloader, entry.Params...)
+	if err != nil {
+		return nil, err
+	}
+	data, err := downloader.Download()
+	if err != nil {
+		cd.logger.Errorf("Error downloading chunk %d: %+v", i, err)
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	expected, err := hex.DecodeString(entry.SHA256)
+	if err != nil || !bytes.Equal(hash[:], expected) || int64(len(data)) != entry.Size {
+		cd.logger.Errorf("Chunk %d is corrupted", i)
+		return nil, errors.New(fmt.Sprintf("chunk %d is corrupted", i))
+	}
+	return data, nil
+}
@@ -0,0 +1,43 @@
+package downloaders
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxCompositeDepth is maximum nesting depth of composite downloaders (i.e.
+// mirror of chunked one has depth 2). Deeper trees are rejected, so that URL
+// can't make gateway build and run downloaders recursively without limit
+const MaxCompositeDepth = 4
+
+// ResolveFunc returns Downloader by name and parameters. It is used by
+// composite downloaders to get downloaders of parts of the content
+type ResolveFunc func(name string, params ...[]byte) (Downloader, error)
+
+// compositeDownloader is Downloader made of other downloaders
+type compositeDownloader interface {
+	// depth returns nesting depth of composite downloaders, 1 if all its
+	// parts are plain ones
+	depth() int
+}
+
+// nestingDepth returns maximum depth among given downloaders. Plain
+// downloaders have depth 0
+func nestingDepth(downloaders ...Downloader) int {
+	result := 0
+	for _, downloader := range downloaders {
+		if composite, ok := downloader.(compositeDownloader); ok {
+			result = max(result, composite.depth())
+		}
+	}
+	return result
+}
+
+// checkNestingDepth returns error if composite downloader made of given parts
+// would be nested deeper than MaxCompositeDepth
+func checkNestingDepth(parts ...Downloader) error {
+	if nestingDepth(parts...)+1 > MaxCompositeDepth {
+		return errors.New(fmt.Sprintf("composite downloaders are nested deeper than %d levels", MaxCompositeDepth))
+	}
+	return nil
+}
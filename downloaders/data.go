@@ -0,0 +1,64 @@
+package downloaders
+
+import (
+	"errors"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// DataDownloaderName is data downloader component name
+const DataDownloaderName = "data"
+
+// DataDownloaderSchema describes data downloader and its params
+var DataDownloaderSchema = common.ComponentSchema{
+	Name:        DataDownloaderName,
+	Description: "Returns data stored in URL itself",
+	Params: []common.ParamSchema{
+		{Name: "data", Type: common.ParamTypeBytes, Description: "data"},
+	},
+}
+
+type dataDownloader struct {
+	logger logger.Logger
+	data   []byte
+}
+
+// NewDataDownloader returns downloader that doesn't download anything but
+// returns data embedded into ShadowNet URL
+func NewDataDownloader(logger logger.Logger, data []byte) Downloader {
+	return &dataDownloader{
+		logger: logger,
+		data:   data,
+	}
+}
+
+// NewDataDownloaderWithParams returns downloader for a given params. It
+// does expect single param that is data itself. It exists only for
+// convenience doing effectively the same as NewDataDownloader
+func NewDataDownloaderWithParams(logger logger.Logger, params ...[]byte) (Downloader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be only 1 param: data")
+	}
+	return NewDataDownloader(logger, params[0]), nil
+}
+
+// Name returns data downloader name. It is always DataDownloaderName
+func (dd *dataDownloader) Name() string {
+	return DataDownloaderName
+}
+
+// Schema returns schema of data downloader. It is always DataDownloaderSchema
+func (dd *dataDownloader) Schema() common.ComponentSchema {
+	return DataDownloaderSchema
+}
+
+// Params returns data packed into byte array
+func (dd *dataDownloader) Params() [][]byte {
+	return [][]byte{dd.data}
+}
+
+// Download returns embedded data
+func (dd *dataDownloader) Download() ([]byte, error) {
+	return dd.data, nil
+}
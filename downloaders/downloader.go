@@ -1,6 +1,8 @@
 package downloaders
 
 import (
+	"io"
+
 	"github.com/takahawk/shadownet/common"
 )
 
@@ -10,3 +12,12 @@ type Downloader interface {
 	// Download downloads data to a byte array
 	Download() ([]byte, error)
 }
+
+// StreamDownloader is Downloader that is able to provide downloaded data as a
+// stream without holding the whole content in memory
+type StreamDownloader interface {
+	Downloader
+	// DownloadStream returns reader of downloaded data. It should be closed
+	// by caller
+	DownloadStream() (io.ReadCloser, error)
+}
@@ -0,0 +1,94 @@
+package downloaders
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// DropboxDownloaderName is dropbox downloader component name
+const DropboxDownloaderName = "dropbox"
+
+// DropboxDownloaderSchema describes Dropbox downloader and its params
+var DropboxDownloaderSchema = common.ComponentSchema{
+	Name:        DropboxDownloaderName,
+	Description: "Downloads file by Dropbox shared link",
+	Params: []common.ParamSchema{
+		{Name: "link", Type: common.ParamTypeString, Description: "Dropbox shared link"},
+	},
+}
+
+type dropboxDownloader struct {
+	logger     logger.Logger
+	sharedLink string
+}
+
+// NewDropboxDownloader returns downloader that gets content of a file by
+// Dropbox shared link. Link is not required to be a direct download one
+// (i.e. with dl=1), it is converted to direct one automatically
+func NewDropboxDownloader(logger logger.Logger, sharedLink string) (Downloader, error) {
+	u, err := url.Parse(sharedLink)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Hostname()
+	if u.Scheme != "https" || (host != "dropbox.com" && !strings.HasSuffix(host, ".dropbox.com")) {
+		return nil, errors.New(fmt.Sprintf("not a Dropbox shared link: %s", sharedLink))
+	}
+	return &dropboxDownloader{
+		logger:     logger,
+		sharedLink: sharedLink,
+	}, nil
+}
+
+// NewDropboxDownloaderWithParams returns downloader for a given params. It
+// does expect single param that is shared link. It exists only for convenience
+// doing effectively the same as NewDropboxDownloader
+func NewDropboxDownloaderWithParams(logger logger.Logger, params ...[]byte) (Downloader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be only 1 param: Dropbox shared link")
+	}
+	return NewDropboxDownloader(logger, string(params[0]))
+}
+
+// Name returns dropbox downloader name. It is always DropboxDownloaderName
+func (dd *dropboxDownloader) Name() string {
+	return DropboxDownloaderName
+}
+
+// Schema returns schema of Dropbox downloader. It is always DropboxDownloaderSchema
+func (dd *dropboxDownloader) Schema() common.ComponentSchema {
+	return DropboxDownloaderSchema
+}
+
+// Params returns shared link packed into byte array
+func (dd *dropboxDownloader) Params() [][]byte {
+	return [][]byte{[]byte(dd.sharedLink)}
+}
+
+// Download returns content of shared file in a byte array
+func (dd *dropboxDownloader) Download() ([]byte, error) {
+	return dd.directDownloader().Download()
+}
+
+// DownloadStream returns reader of shared file content
+func (dd *dropboxDownloader) DownloadStream() (io.ReadCloser, error) {
+	return dd.directDownloader().DownloadStream()
+}
+
+func (dd *dropboxDownloader) directDownloader() *webDownloader {
+	// link is already validated during creation
+	u, _ := url.Parse(dd.sharedLink)
+	query := u.Query()
+	query.Set("dl", "1")
+	u.RawQuery = query.Encode()
+	return &webDownloader{
+		logger: dd.logger,
+		url:    u.String(),
+	}
+}
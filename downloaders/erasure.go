@@ -0,0 +1,199 @@
+package downloaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// ErasureDownloaderName is erasure downloader component name
+const ErasureDownloaderName = "erasure"
+
+// ErasureDownloaderSchema describes erasure downloader and its params
+var ErasureDownloaderSchema = common.ComponentSchema{
+	Name:        ErasureDownloaderName,
+	Description: "Restores data from erasure coded shards",
+	Params: []common.ParamSchema{
+		{Name: "dataShards", Type: common.ParamTypeInt, Description: "number of data shards"},
+		{Name: "size", Type: common.ParamTypeInt, Description: "size of data"},
+		{Name: "shard", Type: common.ParamTypeBytes, Description: "SHA-256 hash of shard followed by its downloader", Variadic: true},
+	},
+}
+
+// ErasureShard is location of single Reed-Solomon shard along with its
+// SHA-256 hash
+type ErasureShard struct {
+	Downloader Downloader
+	SHA256     []byte
+}
+
+type erasureDownloader struct {
+	logger     logger.Logger
+	dataShards int
+	size       int
+	shards     []ErasureShard
+}
+
+// NewErasureDownloader returns downloader that gets Reed-Solomon shards of
+// content and rebuilds it from any dataShards of them that are available.
+// Size is length of original content
+func NewErasureDownloader(logger logger.Logger, dataShards int, size int, shards []ErasureShard) (Downloader, error) {
+	if dataShards <= 0 || dataShards >= len(shards) {
+		return nil, errors.New("number of data shards should be positive and less than number of all shards")
+	}
+	if size < 0 {
+		return nil, errors.New("size should not be negative")
+	}
+	return &erasureDownloader{
+		logger:     logger,
+		dataShards: dataShards,
+		size:       size,
+		shards:     shards,
+	}, nil
+}
+
+// NewErasureDownloaderWithParams returns erasure downloader for a given
+// params. First two are number of data shards and size of content in
+// decimal form, and every next one is SHA-256 hash of shard followed by its
+// downloader encoded with common.EncodeComponentSpec
+func NewErasureDownloaderWithParams(logger logger.Logger, resolve ResolveFunc, params ...[]byte) (Downloader, error) {
+	if len(params) < 3 {
+		return nil, errors.New("there should be at least 3 params: data shards, size and shards")
+	}
+	dataShards, err := strconv.Atoi(string(params[0]))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid number of data shards: %s", params[0]))
+	}
+	size, err := strconv.Atoi(string(params[1]))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid size: %s", params[1]))
+	}
+
+	var shards []ErasureShard
+	for _, param := range params[2:] {
+		if len(param) < sha256.Size {
+			return nil, errors.New("malformed shard param")
+		}
+		name, downloaderParams, err := common.DecodeComponentSpec(param[sha256.Size:])
+		if err != nil {
+			return nil, err
+		}
+		downloader, err := resolve(name, downloaderParams...)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, ErasureShard{
+			Downloader: downloader,
+			SHA256:     param[:sha256.Size],
+		})
+	}
+	var parts []Downloader
+	for _, shard := range shards {
+		parts = append(parts, shard.Downloader)
+	}
+	err = checkNestingDepth(parts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewErasureDownloader(logger, dataShards, size, shards)
+}
+
+// Name returns erasure downloader name. It is always ErasureDownloaderName
+func (ed *erasureDownloader) Name() string {
+	return ErasureDownloaderName
+}
+
+// Schema returns schema of erasure downloader. It is always ErasureDownloaderSchema
+func (ed *erasureDownloader) Schema() common.ComponentSchema {
+	return ErasureDownloaderSchema
+}
+
+// Params returns number of data shards, size and shards
+func (ed *erasureDownloader) Params() [][]byte {
+	params := [][]byte{
+		[]byte(strconv.Itoa(ed.dataShards)),
+		[]byte(strconv.Itoa(ed.size)),
+	}
+	for _, shard := range ed.shards {
+		spec := common.EncodeComponentSpec(shard.Downloader.Name(), shard.Downloader.Params())
+		params = append(params, append(append([]byte{}, shard.SHA256...), spec...))
+	}
+	return params
+}
+
+func (ed *erasureDownloader) depth() int {
+	depth := 0
+	for _, shard := range ed.shards {
+		depth = max(depth, nestingDepth(shard.Downloader))
+	}
+	return depth + 1
+}
+
+// Download gets all shards in parallel and rebuilds content from them. Shards
+// that can't be downloaded or are corrupted are logged and treated as missing
+func (ed *erasureDownloader) Download() ([]byte, error) {
+	shards := make([][]byte, len(ed.shards))
+	var wg sync.WaitGroup
+	for i := range ed.shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			shards[i] = ed.downloadShard(i)
+		}(i)
+	}
+	wg.Wait()
+
+	var missing []int
+	for i, shard := range shards {
+		if shard == nil {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) != 0 {
+		ed.logger.Errorf("Missing shards: %v (%d of %d are available, %d needed)",
+			missing, len(shards)-len(missing), len(shards), ed.dataShards)
+	}
+	if len(shards)-len(missing) < ed.dataShards {
+		return nil, errors.New(fmt.Sprintf("not enough shards to rebuild content: %d are missing", len(missing)))
+	}
+
+	enc, err := reedsolomon.New(ed.dataShards, len(ed.shards)-ed.dataShards)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.ReconstructData(shards)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = enc.Join(&buf, shards, ed.size)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// downloadShard returns shard content or nil if it is not available
+func (ed *erasureDownloader) downloadShard(i int) []byte {
+	downloader := ed.shards[i].Downloader
+	data, err := downloader.Download()
+	if err != nil {
+		ed.logger.Errorf("Shard %d (%s) is missing: %+v", i, downloader.Name(), err)
+		return nil
+	}
+	hash := sha256.Sum256(data)
+	if !bytes.Equal(hash[:], ed.shards[i].SHA256) {
+		ed.logger.Errorf("Shard %d (%s) is corrupted", i, downloader.Name())
+		return nil
+	}
+	return data
+}
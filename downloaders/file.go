@@ -0,0 +1,130 @@
+package downloaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// FileDownloaderName is file downloader component name
+const FileDownloaderName = "file"
+
+// FileDownloaderSchema describes file downloader and its params
+var FileDownloaderSchema = common.ComponentSchema{
+	Name:        FileDownloaderName,
+	Description: "Reads blob from blob directory of gateway",
+	Params: []common.ParamSchema{
+		{Name: "id", Type: common.ParamTypeString, Description: "blob id (SHA-256 hash in hex)", Lengths: []int{64}},
+	},
+}
+
+type fileDownloader struct {
+	logger logger.Logger
+	dir    string
+	id     string
+}
+
+// NewFileDownloader returns downloader that reads blob saved by file uploader
+// from a given directory. Id is hex SHA-256 hash of content, so it is
+// verified on read
+func NewFileDownloader(logger logger.Logger, dir string, id string) (Downloader, error) {
+	if err := ValidateFileBlobID(id); err != nil {
+		return nil, err
+	}
+	return &fileDownloader{
+		logger: logger,
+		dir:    dir,
+		id:     id,
+	}, nil
+}
+
+// NewFileDownloaderWithParams returns downloader for a given params. It
+// does expect single param that is blob id. Directory is not a param, since
+// it is configuration of gateway and should never get into URLs
+func NewFileDownloaderWithParams(logger logger.Logger, dir string, params ...[]byte) (Downloader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be 1 param: blob id")
+	}
+	return NewFileDownloader(logger, dir, string(params[0]))
+}
+
+// ValidateFileBlobID checks that id is hex SHA-256 hash, so it can't point
+// outside of blob directory
+func ValidateFileBlobID(id string) error {
+	hash, err := hex.DecodeString(id)
+	if err != nil || len(hash) != sha256.Size || hex.EncodeToString(hash) != id {
+		return errors.New(fmt.Sprintf("invalid blob id: %s", id))
+	}
+	return nil
+}
+
+// FileBlobPath returns path of blob with a given id inside of directory.
+// Blobs are spread among subdirectories named by first 2 symbols of id
+func FileBlobPath(dir string, id string) string {
+	return filepath.Join(dir, id[:2], id)
+}
+
+// Name returns file downloader name. It is always FileDownloaderName
+func (fd *fileDownloader) Name() string {
+	return FileDownloaderName
+}
+
+// Schema returns schema of file downloader. It is always FileDownloaderSchema
+func (fd *fileDownloader) Schema() common.ComponentSchema {
+	return FileDownloaderSchema
+}
+
+// Params returns blob id packed into byte array
+func (fd *fileDownloader) Params() [][]byte {
+	return [][]byte{[]byte(fd.id)}
+}
+
+// Download returns content of blob in a byte array
+func (fd *fileDownloader) Download() ([]byte, error) {
+	r, err := fd.DownloadStream()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// DownloadStream returns reader of blob content. Whole blob is checked
+// against its id before anything is returned, so corrupted content is never
+// streamed
+func (fd *fileDownloader) DownloadStream() (io.ReadCloser, error) {
+	path := FileBlobPath(fd.dir, fd.id)
+	fd.logger.Infof("Reading blob %s", path)
+	f, err := os.Open(path)
+	if err != nil {
+		fd.logger.Errorf("%+v", err)
+		return nil, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		fd.logger.Errorf("%+v", err)
+		return nil, err
+	}
+	expected, _ := hex.DecodeString(fd.id)
+	if !bytes.Equal(hash.Sum(nil), expected) {
+		f.Close()
+		err = errors.New(fmt.Sprintf("content of blob %s doesn't match its id", fd.id))
+		fd.logger.Errorf("%+v", err)
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,93 @@
+package downloaders
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// GDriveDownloaderName is gdrive downloader component name
+const GDriveDownloaderName = "gdrive"
+
+// GDriveDownloaderSchema describes Google Drive downloader and its params
+var GDriveDownloaderSchema = common.ComponentSchema{
+	Name:        GDriveDownloaderName,
+	Description: "Downloads publicly shared Google Drive file",
+	Params: []common.ParamSchema{
+		{Name: "fileId", Type: common.ParamTypeString, Description: "Google Drive file id"},
+	},
+}
+
+// GDriveDownloadUrl is URL to download content of files shared by link
+const GDriveDownloadUrl = "https://drive.usercontent.google.com/download"
+
+var gdriveFileIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type gdriveDownloader struct {
+	logger logger.Logger
+	fileId string
+}
+
+// NewGDriveDownloader returns downloader that gets content of Google Drive
+// file shared with anyone who has link by its id
+func NewGDriveDownloader(logger logger.Logger, fileId string) (Downloader, error) {
+	if !gdriveFileIdPattern.MatchString(fileId) {
+		return nil, errors.New(fmt.Sprintf("invalid Google Drive file id: %s", fileId))
+	}
+	return &gdriveDownloader{
+		logger: logger,
+		fileId: fileId,
+	}, nil
+}
+
+// NewGDriveDownloaderWithParams returns downloader for a given params. It
+// does expect single param that is file id. It exists only for convenience
+// doing effectively the same as NewGDriveDownloader
+func NewGDriveDownloaderWithParams(logger logger.Logger, params ...[]byte) (Downloader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be only 1 param: Google Drive file id")
+	}
+	return NewGDriveDownloader(logger, string(params[0]))
+}
+
+// Name returns gdrive downloader name. It is always GDriveDownloaderName
+func (gd *gdriveDownloader) Name() string {
+	return GDriveDownloaderName
+}
+
+// Schema returns schema of Google Drive downloader. It is always GDriveDownloaderSchema
+func (gd *gdriveDownloader) Schema() common.ComponentSchema {
+	return GDriveDownloaderSchema
+}
+
+// Params returns file id packed into byte array
+func (gd *gdriveDownloader) Params() [][]byte {
+	return [][]byte{[]byte(gd.fileId)}
+}
+
+// Download returns content of shared file in a byte array
+func (gd *gdriveDownloader) Download() ([]byte, error) {
+	return gd.directDownloader().Download()
+}
+
+// DownloadStream returns reader of shared file content
+func (gd *gdriveDownloader) DownloadStream() (io.ReadCloser, error) {
+	return gd.directDownloader().DownloadStream()
+}
+
+func (gd *gdriveDownloader) directDownloader() *webDownloader {
+	query := url.Values{}
+	query.Set("id", gd.fileId)
+	query.Set("export", "download")
+	// skips virus scan warning page for large files
+	query.Set("confirm", "t")
+	return &webDownloader{
+		logger: gd.logger,
+		url:    GDriveDownloadUrl + "?" + query.Encode(),
+	}
+}
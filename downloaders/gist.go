@@ -0,0 +1,86 @@
+package downloaders
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// GistDownloaderName is gist downloader component name
+const GistDownloaderName = "gist"
+
+// GistDownloaderSchema describes gist downloader and its params
+var GistDownloaderSchema = common.ComponentSchema{
+	Name:        GistDownloaderName,
+	Description: "Downloads raw content of GitHub gist file",
+	Params: []common.ParamSchema{
+		{Name: "rawUrl", Type: common.ParamTypeString, Description: "raw URL of gist file"},
+	},
+}
+
+type gistDownloader struct {
+	logger logger.Logger
+	rawUrl string
+}
+
+// NewGistDownloader returns downloader that gets content of gist file by its
+// raw URL
+func NewGistDownloader(logger logger.Logger, rawUrl string) (Downloader, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New(fmt.Sprintf("not a gist raw URL: %s", rawUrl))
+	}
+	return &gistDownloader{
+		logger: logger,
+		rawUrl: rawUrl,
+	}, nil
+}
+
+// NewGistDownloaderWithParams returns downloader for a given params. It
+// does expect single param that is raw URL. It exists only for convenience
+// doing effectively the same as NewGistDownloader
+func NewGistDownloaderWithParams(logger logger.Logger, params ...[]byte) (Downloader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be only 1 param: gist raw URL")
+	}
+	return NewGistDownloader(logger, string(params[0]))
+}
+
+// Name returns gist downloader name. It is always GistDownloaderName
+func (gd *gistDownloader) Name() string {
+	return GistDownloaderName
+}
+
+// Schema returns schema of gist downloader. It is always GistDownloaderSchema
+func (gd *gistDownloader) Schema() common.ComponentSchema {
+	return GistDownloaderSchema
+}
+
+// Params returns raw URL packed into byte array
+func (gd *gistDownloader) Params() [][]byte {
+	return [][]byte{[]byte(gd.rawUrl)}
+}
+
+// Download returns content of gist file in a byte array
+func (gd *gistDownloader) Download() ([]byte, error) {
+	return gd.rawDownloader().Download()
+}
+
+// DownloadStream returns reader of gist file content
+func (gd *gistDownloader) DownloadStream() (io.ReadCloser, error) {
+	return gd.rawDownloader().DownloadStream()
+}
+
+func (gd *gistDownloader) rawDownloader() *webDownloader {
+	return &webDownloader{
+		logger: gd.logger,
+		url:    gd.rawUrl,
+	}
+}
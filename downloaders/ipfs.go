@@ -0,0 +1,127 @@
+package downloaders
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// IPFSDownloaderName is ipfs downloader component name
+const IPFSDownloaderName = "ipfs"
+
+// IPFSDownloaderSchema describes IPFS downloader and its params
+var IPFSDownloaderSchema = common.ComponentSchema{
+	Name:        IPFSDownloaderName,
+	Description: "Gets content from IPFS gateway or RPC API",
+	Params: []common.ParamSchema{
+		{Name: "base", Type: common.ParamTypeString, Description: "gateway URL or RPC API URL ending with /api/v0"},
+		{Name: "cid", Type: common.ParamTypeString, Description: "content id"},
+	},
+}
+
+// IPFSApiPath is path of IPFS node RPC API
+const IPFSApiPath = "/api/v0"
+
+var ipfsCidPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+type ipfsDownloader struct {
+	logger logger.Logger
+	base   string
+	cid    string
+}
+
+// NewIPFSDownloader returns downloader that gets IPFS content by CID. Base is
+// either URL of HTTP gateway or RPC API of IPFS node if it ends with
+// IPFSApiPath
+func NewIPFSDownloader(logger logger.Logger, base, cid string) (Downloader, error) {
+	if err := ValidateIPFSBase(base); err != nil {
+		return nil, err
+	}
+	if !ipfsCidPattern.MatchString(cid) {
+		return nil, errors.New(fmt.Sprintf("invalid CID: %s", cid))
+	}
+	return &ipfsDownloader{
+		logger: logger,
+		base:   strings.TrimSuffix(base, "/"),
+		cid:    cid,
+	}, nil
+}
+
+// NewIPFSDownloaderWithParams returns downloader for a given params. It
+// does expect 2 params: gateway or RPC API URL and CID. It exists only for
+// convenience doing effectively the same as NewIPFSDownloader
+func NewIPFSDownloaderWithParams(logger logger.Logger, params ...[]byte) (Downloader, error) {
+	if len(params) != 2 {
+		return nil, errors.New("there should be 2 params: gateway or RPC API URL and CID")
+	}
+	return NewIPFSDownloader(logger, string(params[0]), string(params[1]))
+}
+
+// ValidateIPFSBase checks that URL of IPFS gateway or node is HTTP(S) one
+func ValidateIPFSBase(base string) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New(fmt.Sprintf("not an HTTP(S) URL: %s", base))
+	}
+	return nil
+}
+
+// Name returns ipfs downloader name. It is always IPFSDownloaderName
+func (id *ipfsDownloader) Name() string {
+	return IPFSDownloaderName
+}
+
+// Schema returns schema of IPFS downloader. It is always IPFSDownloaderSchema
+func (id *ipfsDownloader) Schema() common.ComponentSchema {
+	return IPFSDownloaderSchema
+}
+
+// Params returns gateway or RPC API URL and CID packed into byte arrays
+func (id *ipfsDownloader) Params() [][]byte {
+	return [][]byte{[]byte(id.base), []byte(id.cid)}
+}
+
+// Download returns content in a byte array
+func (id *ipfsDownloader) Download() ([]byte, error) {
+	body, err := id.DownloadStream()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// DownloadStream returns reader of content as it is being received
+func (id *ipfsDownloader) DownloadStream() (io.ReadCloser, error) {
+	if !strings.HasSuffix(id.base, IPFSApiPath) {
+		gateway := &webDownloader{
+			logger: id.logger,
+			url:    fmt.Sprintf("%s/ipfs/%s", id.base, id.cid),
+		}
+		return gateway.DownloadStream()
+	}
+
+	// RPC API accepts only POST requests
+	id.logger.Infof("Getting %s from IPFS node %s", id.cid, id.base)
+	rsp, err := http.Post(fmt.Sprintf("%s/cat?arg=%s", id.base, id.cid), "", nil)
+	if err != nil {
+		id.logger.Errorf("%+v", err)
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		id.logger.Errorf("Request failed with status code: %d", rsp.StatusCode)
+		return nil, errors.New(fmt.Sprintf("request failed with status code: %d", rsp.StatusCode))
+	}
+	return rsp.Body, nil
+}
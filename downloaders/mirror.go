@@ -0,0 +1,116 @@
+package downloaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// MirrorDownloaderName is mirror downloader component name
+const MirrorDownloaderName = "mirror"
+
+// MirrorDownloaderSchema describes mirror downloader and its params
+var MirrorDownloaderSchema = common.ComponentSchema{
+	Name:        MirrorDownloaderName,
+	Description: "Downloads data from the first mirror with correct checksum",
+	Params: []common.ParamSchema{
+		{Name: "sha256", Type: common.ParamTypeBytes, Description: "SHA-256 hash of data", Lengths: []int{sha256.Size}},
+		{Name: "mirror", Type: common.ParamTypeComponent, Description: "downloader of mirror", Variadic: true},
+	},
+}
+
+type mirrorDownloader struct {
+	logger  logger.Logger
+	sha256  []byte
+	mirrors []Downloader
+}
+
+// NewMirrorDownloader returns downloader that tries to get content from
+// mirrors one by one in a given order until it gets one matching SHA-256
+// hash
+func NewMirrorDownloader(logger logger.Logger, sha256Hash []byte, mirrors ...Downloader) (Downloader, error) {
+	if len(sha256Hash) != sha256.Size {
+		return nil, errors.New("invalid SHA-256 hash length")
+	}
+	if len(mirrors) == 0 {
+		return nil, errors.New("there should be at least one mirror")
+	}
+	return &mirrorDownloader{
+		logger:  logger,
+		sha256:  sha256Hash,
+		mirrors: mirrors,
+	}, nil
+}
+
+// NewMirrorDownloaderWithParams returns mirror downloader for a given params.
+// The first one is SHA-256 hash of content and every next one is downloader
+// of mirror encoded with common.EncodeComponentSpec
+func NewMirrorDownloaderWithParams(logger logger.Logger, resolve ResolveFunc, params ...[]byte) (Downloader, error) {
+	if len(params) < 2 {
+		return nil, errors.New("there should be at least 2 params: hash and mirror")
+	}
+
+	var mirrors []Downloader
+	for _, param := range params[1:] {
+		name, downloaderParams, err := common.DecodeComponentSpec(param)
+		if err != nil {
+			return nil, err
+		}
+		mirror, err := resolve(name, downloaderParams...)
+		if err != nil {
+			return nil, err
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	err := checkNestingDepth(mirrors...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMirrorDownloader(logger, params[0], mirrors...)
+}
+
+// Name returns mirror downloader name. It is always MirrorDownloaderName
+func (md *mirrorDownloader) Name() string {
+	return MirrorDownloaderName
+}
+
+// Schema returns schema of mirror downloader. It is always MirrorDownloaderSchema
+func (md *mirrorDownloader) Schema() common.ComponentSchema {
+	return MirrorDownloaderSchema
+}
+
+// Params returns hash of content followed by mirrors
+func (md *mirrorDownloader) Params() [][]byte {
+	params := [][]byte{md.sha256}
+	for _, mirror := range md.mirrors {
+		params = append(params, common.EncodeComponentSpec(mirror.Name(), mirror.Params()))
+	}
+	return params
+}
+
+func (md *mirrorDownloader) depth() int {
+	return nestingDepth(md.mirrors...) + 1
+}
+
+// Download returns content of the first mirror that is available and isn't
+// corrupted
+func (md *mirrorDownloader) Download() ([]byte, error) {
+	for i, mirror := range md.mirrors {
+		data, err := mirror.Download()
+		if err != nil {
+			md.logger.Errorf("Mirror %d (%s) is not available: %+v", i, mirror.Name(), err)
+			continue
+		}
+		hash := sha256.Sum256(data)
+		if !bytes.Equal(hash[:], md.sha256) {
+			md.logger.Errorf("Mirror %d (%s) has bad checksum", i, mirror.Name())
+			continue
+		}
+		return data, nil
+	}
+	return nil, errors.New("none of mirrors is available")
+}
@@ -0,0 +1,81 @@
+package downloaders
+
+import (
+	"errors"
+	"io"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// PastebinDownloaderName is pastebin downloader component name
+const PastebinDownloaderName = "pastebin"
+
+// PastebinDownloaderSchema describes pastebin downloader and its params
+var PastebinDownloaderSchema = common.ComponentSchema{
+	Name:        PastebinDownloaderName,
+	Description: "Downloads raw paste from pastebin.com",
+	Params: []common.ParamSchema{
+		{Name: "key", Type: common.ParamTypeString, Description: "paste key"},
+	},
+}
+
+// PastebinRawPrefix is prefix for URL used to get saved paste in raw
+// (e.g. https://pastebin.com/raw/y1FKvrXe)
+const PastebinRawPrefix = "https://pastebin.com/raw"
+
+type pastebinDownloader struct {
+	logger   logger.Logger
+	pasteKey string
+}
+
+// NewPastebinDownloader returns downloader that gets raw content of a paste
+// with a given key (e.g. y1FKvrXe)
+func NewPastebinDownloader(logger logger.Logger, pasteKey string) Downloader {
+	return &pastebinDownloader{
+		logger:   logger,
+		pasteKey: pasteKey,
+	}
+}
+
+// NewPastebinDownloaderWithParams returns downloader for a given params. It
+// does expect single param that is paste key. It exists only for convenience
+// doing effectively the same as NewPastebinDownloader
+func NewPastebinDownloaderWithParams(logger logger.Logger, params ...[]byte) (Downloader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be only 1 param: paste key")
+	}
+	return NewPastebinDownloader(logger, string(params[0])), nil
+}
+
+// Name returns pastebin downloader name. It is always PastebinDownloaderName
+func (pd *pastebinDownloader) Name() string {
+	return PastebinDownloaderName
+}
+
+// Schema returns schema of pastebin downloader. It is always PastebinDownloaderSchema
+func (pd *pastebinDownloader) Schema() common.ComponentSchema {
+	return PastebinDownloaderSchema
+}
+
+// Params returns paste key packed into byte array
+func (pd *pastebinDownloader) Params() [][]byte {
+	return [][]byte{[]byte(pd.pasteKey)}
+}
+
+// Download returns raw content of the paste in a byte array
+func (pd *pastebinDownloader) Download() ([]byte, error) {
+	return pd.rawDownloader().Download()
+}
+
+// DownloadStream returns reader of raw content of the paste
+func (pd *pastebinDownloader) DownloadStream() (io.ReadCloser, error) {
+	return pd.rawDownloader().DownloadStream()
+}
+
+func (pd *pastebinDownloader) rawDownloader() *webDownloader {
+	return &webDownloader{
+		logger: pd.logger,
+		url:    PastebinRawPrefix + "/" + pd.pasteKey,
+	}
+}
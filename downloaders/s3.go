@@ -0,0 +1,105 @@
+package downloaders
+
+import (
+	"errors"
+	"io"
+	"net/url"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// S3DownloaderName is s3 downloader component name
+const S3DownloaderName = "s3"
+
+// S3DownloaderSchema describes S3 downloader and its params
+var S3DownloaderSchema = common.ComponentSchema{
+	Name:        S3DownloaderName,
+	Description: "Downloads public object from S3-compatible storage",
+	Params: []common.ParamSchema{
+		{Name: "endpoint", Type: common.ParamTypeString, Description: "endpoint URL"},
+		{Name: "bucket", Type: common.ParamTypeString, Description: "bucket name"},
+		{Name: "key", Type: common.ParamTypeString, Description: "object key"},
+	},
+}
+
+type s3Downloader struct {
+	logger   logger.Logger
+	endpoint string
+	bucket   string
+	key      string
+}
+
+// NewS3Downloader returns downloader that gets object by key from a bucket
+// of S3-compatible storage. Requests are anonymous, so bucket should allow
+// public reads
+func NewS3Downloader(logger logger.Logger, endpoint, bucket, key string) (Downloader, error) {
+	if _, err := S3ObjectURL(endpoint, bucket, key); err != nil {
+		return nil, err
+	}
+	return &s3Downloader{
+		logger:   logger,
+		endpoint: endpoint,
+		bucket:   bucket,
+		key:      key,
+	}, nil
+}
+
+// NewS3DownloaderWithParams returns downloader for a given params. It
+// does expect 3 params: endpoint, bucket and object key. It exists only for
+// convenience doing effectively the same as NewS3Downloader
+func NewS3DownloaderWithParams(logger logger.Logger, params ...[]byte) (Downloader, error) {
+	if len(params) != 3 {
+		return nil, errors.New("there should be 3 params: endpoint, bucket and object key")
+	}
+	return NewS3Downloader(logger, string(params[0]), string(params[1]), string(params[2]))
+}
+
+// S3ObjectURL returns path-style URL of object
+func S3ObjectURL(endpoint, bucket, key string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("S3 endpoint should be HTTP(S) URL")
+	}
+	if bucket == "" || key == "" {
+		return "", errors.New("S3 bucket and object key should not be empty")
+	}
+	return u.JoinPath(bucket, key).String(), nil
+}
+
+// Name returns s3 downloader name. It is always S3DownloaderName
+func (sd *s3Downloader) Name() string {
+	return S3DownloaderName
+}
+
+// Schema returns schema of S3 downloader. It is always S3DownloaderSchema
+func (sd *s3Downloader) Schema() common.ComponentSchema {
+	return S3DownloaderSchema
+}
+
+// Params returns endpoint, bucket and object key packed into byte arrays
+func (sd *s3Downloader) Params() [][]byte {
+	return [][]byte{[]byte(sd.endpoint), []byte(sd.bucket), []byte(sd.key)}
+}
+
+// Download returns content of object in a byte array
+func (sd *s3Downloader) Download() ([]byte, error) {
+	return sd.objectDownloader().Download()
+}
+
+// DownloadStream returns reader of object content
+func (sd *s3Downloader) DownloadStream() (io.ReadCloser, error) {
+	return sd.objectDownloader().DownloadStream()
+}
+
+func (sd *s3Downloader) objectDownloader() *webDownloader {
+	// URL is already validated during creation
+	objectUrl, _ := S3ObjectURL(sd.endpoint, sd.bucket, sd.key)
+	return &webDownloader{
+		logger: sd.logger,
+		url:    objectUrl,
+	}
+}
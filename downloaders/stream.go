@@ -0,0 +1,31 @@
+package downloaders
+
+import (
+	"bytes"
+	"io"
+)
+
+type bufferedStreamDownloader struct {
+	Downloader
+}
+
+// NewStreamDownloader returns StreamDownloader for a given downloader. If
+// downloader doesn't support streaming by itself, the returned one downloads
+// the whole content into memory and gives reader over it
+func NewStreamDownloader(downloader Downloader) StreamDownloader {
+	if sd, ok := downloader.(StreamDownloader); ok {
+		return sd
+	}
+	return &bufferedStreamDownloader{
+		Downloader: downloader,
+	}
+}
+
+// DownloadStream downloads the whole content and returns reader over it
+func (bsd *bufferedStreamDownloader) DownloadStream() (io.ReadCloser, error) {
+	data, err := bsd.Download()
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -2,20 +2,33 @@ package downloaders
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/takahawk/shadownet/common"
 	"github.com/takahawk/shadownet/logger"
 )
 
 type webDownloader struct {
 	logger logger.Logger
 	url    string
+	// authorization is optional value of Authorization header
+	authorization string
 }
 
 // WebDownloaderName is web downloader component name
 const WebDownloaderName = "web"
 
+// WebDownloaderSchema describes web downloader and its params
+var WebDownloaderSchema = common.ComponentSchema{
+	Name:        WebDownloaderName,
+	Description: "Downloads data with HTTP GET",
+	Params: []common.ParamSchema{
+		{Name: "url", Type: common.ParamTypeString, Description: "URL of data"},
+	},
+}
+
 // NewWebDownloader returns downloader that downloads the data by the given
 // URL using HTTP request
 func NewWebDownloader(logger logger.Logger, url string) Downloader {
@@ -40,6 +53,11 @@ func (wd *webDownloader) Name() string {
 	return WebDownloaderName
 }
 
+// Schema returns schema of web downloader. It is always WebDownloaderSchema
+func (wd *webDownloader) Schema() common.ComponentSchema {
+	return WebDownloaderSchema
+}
+
 // Params returns url packed into byte array
 func (wd *webDownloader) Params() [][]byte {
 	return [][]byte{[]byte(wd.url)}
@@ -47,16 +65,13 @@ func (wd *webDownloader) Params() [][]byte {
 
 // Download returns downloaded data in a byte array
 func (wd *webDownloader) Download() ([]byte, error) {
-	wd.logger.Infof("Downloading data from URL: %s", wd.url)
-	res, err := http.Get(wd.url)
+	body, err := wd.DownloadStream()
 	if err != nil {
-		wd.logger.Errorf("Error downloading data: %+v", err)
-		// TODO: error handling (wrap etc.)?
 		return nil, err
 	}
 
-	content, err := io.ReadAll(res.Body)
-	res.Body.Close()
+	content, err := io.ReadAll(body)
+	body.Close()
 	if err != nil {
 		wd.logger.Errorf("Error reading response: %+v", err)
 		return nil, err
@@ -65,3 +80,29 @@ func (wd *webDownloader) Download() ([]byte, error) {
 	wd.logger.Infof("Successfully downloaded")
 	return content, nil
 }
+
+// DownloadStream returns body of HTTP response as it is being received
+func (wd *webDownloader) DownloadStream() (io.ReadCloser, error) {
+	wd.logger.Infof("Downloading data from URL: %s", wd.url)
+	req, err := http.NewRequest(http.MethodGet, wd.url, nil)
+	if err != nil {
+		wd.logger.Errorf("%+v", err)
+		return nil, err
+	}
+	if wd.authorization != "" {
+		req.Header.Set("Authorization", wd.authorization)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		wd.logger.Errorf("Error downloading data: %+v", err)
+		// TODO: error handling (wrap etc.)?
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		wd.logger.Errorf("Request failed with status code: %d", res.StatusCode)
+		return nil, errors.New(fmt.Sprintf("request failed with status code: %d", res.StatusCode))
+	}
+
+	return res.Body, nil
+}
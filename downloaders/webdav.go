@@ -0,0 +1,123 @@
+package downloaders
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// WebDAVDownloaderName is webdav downloader component name
+const WebDAVDownloaderName = "webdav"
+
+// WebDAVDownloaderSchema describes WebDAV downloader and its params
+var WebDAVDownloaderSchema = common.ComponentSchema{
+	Name:        WebDAVDownloaderName,
+	Description: "Downloads file from WebDAV server",
+	Params: []common.ParamSchema{
+		{Name: "url", Type: common.ParamTypeString, Description: "file URL"},
+		{Name: "user", Type: common.ParamTypeString, Description: "bearer token or user name", Secret: true, Optional: true},
+		{Name: "password", Type: common.ParamTypeString, Description: "password", Secret: true, Optional: true},
+	},
+}
+
+type webdavDownloader struct {
+	logger logger.Logger
+	url    string
+	auth   [][]byte
+}
+
+// NewWebDAVDownloader returns downloader that gets file from WebDAV server by
+// its URL. Auth is optional: none, bearer token or user and password for
+// basic auth
+func NewWebDAVDownloader(logger logger.Logger, fileUrl string, auth ...[]byte) (Downloader, error) {
+	if err := ValidateWebDAVURL(fileUrl); err != nil {
+		return nil, err
+	}
+	if _, err := WebDAVAuthorization(auth...); err != nil {
+		return nil, err
+	}
+	return &webdavDownloader{
+		logger: logger,
+		url:    fileUrl,
+		auth:   auth,
+	}, nil
+}
+
+// NewWebDAVDownloaderWithParams returns downloader for a given params. The
+// first one is file URL, the rest are auth params. It exists only for
+// convenience doing effectively the same as NewWebDAVDownloader
+func NewWebDAVDownloaderWithParams(logger logger.Logger, params ...[]byte) (Downloader, error) {
+	if len(params) == 0 {
+		return nil, errors.New("there should be at least 1 param: file URL")
+	}
+	return NewWebDAVDownloader(logger, string(params[0]), params[1:]...)
+}
+
+// ValidateWebDAVURL checks that URL is HTTP(S) one
+func ValidateWebDAVURL(webdavUrl string) error {
+	u, err := url.Parse(webdavUrl)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New(fmt.Sprintf("not a WebDAV URL: %s", webdavUrl))
+	}
+	return nil
+}
+
+// WebDAVAuthorization returns value of Authorization header for auth params.
+// There are no params for anonymous access, 1 param is bearer token and 2
+// params are user and password for basic auth
+func WebDAVAuthorization(auth ...[]byte) (string, error) {
+	switch len(auth) {
+	case 0:
+		return "", nil
+	case 1:
+		return "Bearer " + string(auth[0]), nil
+	case 2:
+		credentials := string(auth[0]) + ":" + string(auth[1])
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)), nil
+	default:
+		return "", errors.New("WebDAV auth should be either bearer token or user and password")
+	}
+}
+
+// Name returns webdav downloader name. It is always WebDAVDownloaderName
+func (wd *webdavDownloader) Name() string {
+	return WebDAVDownloaderName
+}
+
+// Schema returns schema of WebDAV downloader. It is always WebDAVDownloaderSchema
+func (wd *webdavDownloader) Schema() common.ComponentSchema {
+	return WebDAVDownloaderSchema
+}
+
+// Params returns file URL followed by auth params
+func (wd *webdavDownloader) Params() [][]byte {
+	return append([][]byte{[]byte(wd.url)}, wd.auth...)
+}
+
+// Download returns content of file in a byte array
+func (wd *webdavDownloader) Download() ([]byte, error) {
+	return wd.fileDownloader().Download()
+}
+
+// DownloadStream returns reader of file content
+func (wd *webdavDownloader) DownloadStream() (io.ReadCloser, error) {
+	return wd.fileDownloader().DownloadStream()
+}
+
+func (wd *webdavDownloader) fileDownloader() *webDownloader {
+	// auth is already validated during creation
+	authorization, _ := WebDAVAuthorization(wd.auth...)
+	return &webDownloader{
+		logger:        wd.logger,
+		url:           wd.url,
+		authorization: authorization,
+	}
+}
@@ -0,0 +1,180 @@
+package gateway
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/takahawk/shadownet/models"
+	"github.com/takahawk/shadownet/oauth"
+	"github.com/takahawk/shadownet/uploaders"
+)
+
+func (sg *shadowGateway) handleListAccountsRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	accounts, err := sg.storage.ListAccounts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+
+	// secrets never leave the gateway
+	for _, account := range accounts {
+		hideAccountSecrets(account)
+	}
+
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+
+	w.Write(data)
+}
+
+// handleAddAccountRequest links account with tokens obtained elsewhere, so
+// that OAuth flow through gateway is not needed
+func (sg *shadowGateway) handleAddAccountRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	b, err := io.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	var account models.OAuthAccount
+	err = json.Unmarshal(b, &account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		sg.logger.Errorf("Error unmarshaling account: %+v", err)
+		return
+	}
+	// account without name can't be referred to by uploaders
+	if account.Name == "" {
+		http.Error(w, "name of account is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := oauth.ProviderByName(account.Provider); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if account.AccessToken == "" && account.RefreshToken == "" {
+		http.Error(w, "either access token or refresh token is required", http.StatusBadRequest)
+		return
+	}
+	if account.RefreshToken != "" && (account.ClientID == "" || account.ClientSecret == "") {
+		http.Error(w, "client id and client secret are required to refresh tokens", http.StatusBadRequest)
+		return
+	}
+
+	err = sg.storage.SaveAccount(&account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sg.logger.Infof("Account with name \"%s\" successfully added", account.Name)
+	fmt.Fprintf(w, "Account with name \"%s\" successfully added\n", account.Name)
+}
+
+// handleRevokeAccountRequest revokes tokens of account at provider and
+// removes it from gateway
+func (sg *shadowGateway) handleRevokeAccountRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	vars := mux.Vars(req)
+	accountName := vars["accountName"]
+
+	account, err := sg.storage.LoadAccount(accountName)
+	if err == sql.ErrNoRows {
+		http.Error(w, "account is not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// account is removed anyway, so revocation failure is only reported
+	err = sg.revokeAccount(account)
+	if err != nil {
+		sg.logger.Errorf("Error revoking tokens of account \"%s\": %+v", accountName, err)
+	}
+
+	err = sg.storage.DeleteAccount(accountName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sg.accountMutex.Lock()
+	delete(sg.tokenProviders, accountName)
+	sg.accountMutex.Unlock()
+
+	sg.logger.Infof("Account with name \"%s\" successfully revoked\n", accountName)
+	fmt.Fprintf(w, "Account with name \"%s\" successfully revoked\n", accountName)
+}
+
+// revokeAccount revokes tokens of account at provider
+func (sg *shadowGateway) revokeAccount(account *models.OAuthAccount) error {
+	provider, err := oauth.ProviderByName(account.Provider)
+	if err != nil {
+		return err
+	}
+	if provider.RevokeWithBearer {
+		// such providers revoke only access tokens, so it should be valid
+		token, err := sg.tokenProvider(account.Name).Token()
+		if err != nil {
+			return err
+		}
+		return oauth.Revoke(provider, token)
+	}
+	if account.RefreshToken != "" {
+		return oauth.Revoke(provider, account.RefreshToken)
+	}
+	return oauth.Revoke(provider, account.AccessToken)
+}
+
+// provideTokenProviders sets token providers of linked accounts to all
+// uploaders that use them
+func (sg *shadowGateway) provideTokenProviders(uploaderList []uploaders.Uploader) error {
+	for _, uploader := range uploaderList {
+		au, ok := uploader.(uploaders.AccountUploader)
+		if !ok || au.Account() == "" {
+			continue
+		}
+		_, err := sg.storage.LoadAccount(au.Account())
+		if err == sql.ErrNoRows {
+			return errors.New(fmt.Sprintf("account \"%s\" is not found", au.Account()))
+		}
+		if err != nil {
+			return err
+		}
+		au.SetTokenProvider(sg.tokenProvider(au.Account()))
+	}
+	return nil
+}
+
+// tokenProvider returns token provider of account. The same provider is
+// shared by all uploads, so that token is refreshed only once
+func (sg *shadowGateway) tokenProvider(name string) oauth.TokenProvider {
+	sg.accountMutex.Lock()
+	defer sg.accountMutex.Unlock()
+	provider, ok := sg.tokenProviders[name]
+	if !ok {
+		provider = oauth.NewAccountTokenProvider(sg.logger, sg.storage, name)
+		sg.tokenProviders[name] = provider
+	}
+	return provider
+}
+
+func hideAccountSecrets(account *models.OAuthAccount) {
+	account.ClientSecret = ""
+	account.AccessToken = ""
+	account.RefreshToken = ""
+}
@@ -0,0 +1,28 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/takahawk/shadownet/resolvers"
+)
+
+// handleListComponentsRequest returns schemas of all components gateway is
+// able to resolve, so that UIs and CLIs can build pipeline specs from them
+func (sg *shadowGateway) handleListComponentsRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	lister, ok := sg.resolver.(resolvers.ComponentLister)
+	if !ok {
+		http.Error(w, "gateway resolver is not able to list components", http.StatusNotImplemented)
+		return
+	}
+
+	data, err := json.Marshal(lister.ListComponents())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+
+	w.Write(data)
+}
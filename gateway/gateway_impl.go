@@ -1,44 +1,96 @@
 package gateway
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
 
 	"github.com/gorilla/mux"
+	"github.com/takahawk/shadownet/common"
 	"github.com/takahawk/shadownet/logger"
 	"github.com/takahawk/shadownet/models"
+	"github.com/takahawk/shadownet/oauth"
 	"github.com/takahawk/shadownet/pipelines"
 	"github.com/takahawk/shadownet/resolvers"
 	"github.com/takahawk/shadownet/storages"
+	"github.com/takahawk/shadownet/transformers"
+	"github.com/takahawk/shadownet/uploaders"
+	"github.com/takahawk/shadownet/url"
+	"gopkg.in/yaml.v3"
 )
 
 type shadowGateway struct {
-	logger  logger.Logger
-	storage storages.Storage
+	logger   logger.Logger
+	storage  storages.Storage
+	resolver resolvers.Resolver
+	// externalURL is base URL gateway is reachable at by clients. If it is
+	// empty, it is taken from request
+	externalURL string
 	// TODO: cache pipelines?
+
+	// oauthStates are pending authorizations by state param
+	oauthStates map[string]oauthState
+	oauthMutex  sync.Mutex
+
+	// tokenProviders are token providers of linked accounts by account name
+	tokenProviders map[string]oauth.TokenProvider
+	accountMutex   sync.Mutex
 }
 
-func NewShadowGateway(logger logger.Logger, storage storages.Storage) ShadownetGateway {
+func NewShadowGateway(logger logger.Logger, storage storages.Storage, resolver resolvers.Resolver) ShadownetGateway {
 	// TODO: check for nil parameters
 	return &shadowGateway{
-		logger:  logger,
-		storage: storage,
+		logger:         logger,
+		storage:        storage,
+		resolver:       resolver,
+		oauthStates:    make(map[string]oauthState),
+		tokenProviders: make(map[string]oauth.TokenProvider),
+	}
+}
+
+// NewShadowGatewayWithExternalURL returns gateway that is reachable by
+// clients at a given base URL (i.e. https://shadownet.example.com behind
+// reverse proxy). It is used for URLs gateway gives out, such as OAuth
+// callback, instead of Host header of request
+func NewShadowGatewayWithExternalURL(logger logger.Logger, storage storages.Storage, resolver resolvers.Resolver, externalURL string) (ShadownetGateway, error) {
+	u, err := neturl.Parse(externalURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New(fmt.Sprintf("invalid external URL of gateway: %s", externalURL))
 	}
+	sg := NewShadowGateway(logger, storage, resolver).(*shadowGateway)
+	sg.externalURL = strings.TrimSuffix(externalURL, "/")
+	return sg, nil
 }
 
 func (sg *shadowGateway) Start(port int) error {
 	r := mux.NewRouter()
 	r.HandleFunc("/pipelines", sg.handleListPipelinesRequest).Methods(http.MethodGet)
+	r.HandleFunc("/keys", sg.handleListKeysRequest).Methods(http.MethodGet)
+	r.HandleFunc("/accounts", sg.handleListAccountsRequest).Methods(http.MethodGet)
+	r.HandleFunc("/components", sg.handleListComponentsRequest).Methods(http.MethodGet)
+	r.HandleFunc("/secrets", sg.handleListSecretsRequest).Methods(http.MethodGet)
 	r.HandleFunc("/{shadowUrl}", sg.handleGatewayRequest).Methods(http.MethodGet)
 	r.HandleFunc("/pipelines", sg.handleAddPipelineRequest).Methods(http.MethodPost)
 	r.HandleFunc("/pipelines", sg.handleUpdatePipelineRequest).Methods(http.MethodPut)
 	r.HandleFunc("/pipelines/{pipelineName}", sg.handleDeletePipelineRequest).Methods(http.MethodDelete)
+	r.HandleFunc("/keys", sg.handleAddKeyRequest).Methods(http.MethodPost)
+	r.HandleFunc("/keys/{keyName}", sg.handleDeleteKeyRequest).Methods(http.MethodDelete)
+	r.HandleFunc("/accounts", sg.handleAddAccountRequest).Methods(http.MethodPost)
+	r.HandleFunc("/accounts/{accountName}", sg.handleRevokeAccountRequest).Methods(http.MethodDelete)
+	r.HandleFunc("/secrets", sg.handleSaveSecretRequest).Methods(http.MethodPost)
+	r.HandleFunc("/secrets/{secretName}", sg.handleDeleteSecretRequest).Methods(http.MethodDelete)
+	r.HandleFunc("/oauth/{provider}/authorize", sg.handleAuthorizeRequest).Methods(http.MethodPost)
+	r.HandleFunc("/oauth/{provider}/authorize", sg.handleAuthorizeRedirectRequest).Methods(http.MethodGet)
+	r.HandleFunc("/oauth/{provider}/callback", sg.handleCallbackRequest).Methods(http.MethodGet)
 
 	r.HandleFunc("/pipelines/{pipelineName}/upload", sg.handleUploadFileRequest).Methods(http.MethodPost)
+	// passphrase form for protected content is submitted with POST
+	r.HandleFunc("/{shadowUrl}", sg.handleGatewayRequest).Methods(http.MethodPost)
 	http.Handle("/", r)
 	sg.logger.Infof("Starting ShadowNet gateway on port %d", port)
 
@@ -50,14 +102,19 @@ func (sg *shadowGateway) Start(port int) error {
 func (sg *shadowGateway) handleGatewayRequest(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	shadowUrl := vars["shadowUrl"]
-	pipeline, err := pipelines.NewDownloadPipelineByURL(sg.logger, shadowUrl)
+	components, err := url.NewUrlHandler(sg.logger, sg.resolver).GetDownloadComponents(shadowUrl)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		sg.logger.Errorf("%+v", err)
 		fmt.Fprintf(w, "%+v", err)
 		return
 	}
-	data, err := pipeline.Download()
+
+	if !sg.providePassphrase(w, req, components) {
+		return
+	}
+
+	err = sg.provideKeys(components)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		sg.logger.Errorf("%+v", err)
@@ -65,7 +122,41 @@ func (sg *shadowGateway) handleGatewayRequest(w http.ResponseWriter, req *http.R
 		return
 	}
 
-	fmt.Fprintf(w, string(data))
+	pipeline := pipelines.NewDownloadPipeline(sg.logger)
+	err = pipeline.AddSteps(components...)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		fmt.Fprintf(w, "%+v", err)
+		return
+	}
+	r, err := pipeline.DownloadReader()
+	if err != nil {
+		sg.logger.Errorf("%+v", err)
+		var integrityErr *transformers.IntegrityError
+		if errors.As(err, &integrityErr) && integrityErr.Component == transformers.PassphraseEncryptorName {
+			writePassphraseForm(w, "Wrong passphrase")
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%+v", err)
+		return
+	}
+	defer r.Close()
+	// signatures are already verified at this point, so headers are sent
+	// even if content is empty
+	sg.setVerifiedHeaders(w, components)
+	rw := &responseWriter{ResponseWriter: w}
+	_, err = io.Copy(rw, r)
+	if err != nil {
+		sg.logger.Errorf("%+v", err)
+		// it is too late to report error if part of response is already sent
+		if !rw.written {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "%+v", err)
+		}
+		return
+	}
 }
 
 func (sg *shadowGateway) handleListPipelinesRequest(w http.ResponseWriter, req *http.Request) {
@@ -77,14 +168,19 @@ func (sg *shadowGateway) handleListPipelinesRequest(w http.ResponseWriter, req *
 		return
 	}
 
-	data, err := json.Marshal(pipelineSpecs)
+	var data []byte
+	if strings.Contains(req.Header.Get("Accept"), "yaml") {
+		data, err = yaml.Marshal(pipelineSpecs)
+	} else {
+		data, err = json.Marshal(pipelineSpecs)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		sg.logger.Errorf("%+v", err)
 		return
 	}
 
-	fmt.Fprintf(w, string(data))
+	w.Write(data)
 }
 
 func (sg *shadowGateway) handleAddPipelineRequest(w http.ResponseWriter, req *http.Request) {
@@ -96,22 +192,20 @@ func (sg *shadowGateway) handleAddPipelineRequest(w http.ResponseWriter, req *ht
 		sg.logger.Errorf("%+v", err)
 		return
 	}
-	pipelineJson := string(b)
-	var pipelineSpec models.PipelineSpec
-	err = json.Unmarshal([]byte(pipelineJson), &pipelineSpec)
+	pipelineSpec, err := models.ParsePipelineSpec(b, isYAML(req))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		sg.logger.Errorf("Error unmarshaling pipeline spec: %+v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		sg.logger.Errorf("Error parsing pipeline spec: %+v", err)
 		return
 	}
-	_, err = sg.parsePipeline(&pipelineSpec)
+	_, err = sg.parsePipeline(pipelineSpec)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		sg.logger.Errorf("Error parsing pipeline: %+v", err)
 		return
 	}
 
-	err = sg.storage.SavePipelineSpec(&pipelineSpec)
+	err = sg.storage.SavePipelineSpec(pipelineSpec)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -131,20 +225,18 @@ func (sg *shadowGateway) handleUpdatePipelineRequest(w http.ResponseWriter, req
 		sg.logger.Errorf("%+v", err)
 		return
 	}
-	pipelineJson := string(b)
-	var pipelineSpec models.PipelineSpec
-	err = json.Unmarshal([]byte(pipelineJson), &pipelineSpec)
+	pipelineSpec, err := models.ParsePipelineSpec(b, isYAML(req))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		sg.logger.Errorf("Error unmarshaling pipeline spec: %+v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		sg.logger.Errorf("Error parsing pipeline spec: %+v", err)
 		return
 	}
-	_, err = sg.parsePipeline(&pipelineSpec)
+	_, err = sg.parsePipeline(pipelineSpec)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = sg.storage.UpdatePipelineSpec(&pipelineSpec)
+	err = sg.storage.UpdatePipelineSpec(pipelineSpec)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -179,36 +271,32 @@ func (sg *shadowGateway) handleUploadFileRequest(w http.ResponseWriter, req *htt
 		return
 	}
 
-	file, _, err := req.FormFile("file")
+	file, err := uploadedFile(req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		sg.logger.Errorf("%+v", err)
 		return
 	}
 	defer file.Close()
 
-	// TODO: mb better to make it work with Reader interface
-	b, err := io.ReadAll(file)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		sg.logger.Errorf("%+v", err)
-		return
-	}
-
 	pipeline, err := sg.parsePipeline(pipelineSpec)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	url, err := pipeline.Upload(b)
+	url, err := pipeline.UploadStream(file)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		sg.logger.Errorf("%+v", err)
 		return
 	}
 
-	response := make(map[string]string)
+	response := make(map[string]any)
 	response["url"] = url
+	// i.e. mirrors that failed while enough of the others succeeded
+	if warnings := pipeline.Warnings(); len(warnings) != 0 {
+		response["warnings"] = warnings
+	}
 	responseJson, err := json.Marshal(response)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -226,28 +314,61 @@ func (sg *shadowGateway) parsePipeline(pipelineSpec *models.PipelineSpec) (pipel
 	}
 
 	var byteParams [][][]byte
-	for j, component := range pipelineSpec.Components {
-		byteParams = append(byteParams, nil)
+	for _, component := range pipelineSpec.Components {
+		var params [][]byte
 		for _, param := range component.Params {
-			if component.IsParamsBase64d {
-				decoded, err := base64.StdEncoding.DecodeString(param)
-				if err != nil {
-					sg.logger.Errorf("%+v", err)
-					return nil, err
-				}
-				byteParams[j] = append(byteParams[j], decoded)
-			} else {
-				byteParams[j] = append(byteParams[j], []byte(param))
+			decoded, err := param.Decode(sg.lookupSecret)
+			if err != nil {
+				err = errors.New(fmt.Sprintf("component %s: %+v", component.Name, err))
+				sg.logger.Errorf("%+v", err)
+				return nil, err
 			}
+			params = append(params, decoded)
 		}
+		byteParams = append(byteParams, params)
 	}
 
 	pipeline := pipelines.NewUploadPipeline(sg.logger)
 
-	resolver := resolvers.NewBuiltinResolver(sg.logger)
+	// pipeline ends with one or several uploaders (mirrors) or composite
+	// uploader followed by uploaders it spreads data among, so uploaders are
+	// collected from the end
+	var uploaderList []uploaders.Uploader
+	firstUploader := len(pipelineSpec.Components)
+	for firstUploader > 0 {
+		uploaderSpec := pipelineSpec.Components[firstUploader-1]
+		uploader, err := sg.resolver.ResolveUploader(uploaderSpec.Name, byteParams[firstUploader-1]...)
+		var notFound *resolvers.NotFoundError
+		if err != nil {
+			// the last component should always be uploader. Other errors
+			// mean that it is uploader with invalid params
+			if len(uploaderList) == 0 || !errors.As(err, &notFound) {
+				sg.logger.Errorf("%+v", err)
+				return nil, err
+			}
+			break
+		}
+		err = checkParamNames(uploader, pipelineSpec.Components[firstUploader-1].Params)
+		if err != nil {
+			sg.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		uploaderList = append([]uploaders.Uploader{uploader}, uploaderList...)
+		firstUploader--
+	}
 
-	for i := 0; i < len(pipelineSpec.Components)-1; i++ {
-		transformer, err := resolver.ResolveTransformer(pipelineSpec.Components[i].Name, byteParams[i]...)
+	for i := 0; i < firstUploader; i++ {
+		transformer, err := sg.resolver.ResolveTransformer(pipelineSpec.Components[i].Name, byteParams[i]...)
+		if err != nil {
+			sg.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		err = checkParamNames(transformer, pipelineSpec.Components[i].Params)
+		if err != nil {
+			sg.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		err = sg.provideKeys([]common.Component{transformer})
 		if err != nil {
 			sg.logger.Errorf("%+v", err)
 			return nil, err
@@ -259,10 +380,13 @@ func (sg *shadowGateway) parsePipeline(pipelineSpec *models.PipelineSpec) (pipel
 		}
 	}
 
-	// TODO: mb double-check for uploader and send human-friendly error
-	uploaderSpec := pipelineSpec.Components[len(pipelineSpec.Components)-1]
+	err := sg.provideTokenProviders(uploaderList)
+	if err != nil {
+		sg.logger.Errorf("%+v", err)
+		return nil, err
+	}
 
-	uploader, err := resolver.ResolveUploader(uploaderSpec.Name, byteParams[len(pipelineSpec.Components)-1]...)
+	uploader, err := sg.combineUploaders(uploaderList)
 	if err != nil {
 		sg.logger.Errorf("%+v", err)
 		return nil, err
@@ -277,6 +401,96 @@ func (sg *shadowGateway) parsePipeline(pipelineSpec *models.PipelineSpec) (pipel
 	return pipeline, nil
 }
 
+// combineUploaders returns single uploader for all uploaders at the end of
+// pipeline. If the first one is composite, the rest are added to it.
+// Otherwise data is mirrored to all of them
+func (sg *shadowGateway) combineUploaders(uploaderList []uploaders.Uploader) (uploaders.Uploader, error) {
+	composite, ok := uploaderList[0].(uploaders.CompositeUploader)
+	if !ok {
+		if len(uploaderList) == 1 {
+			return uploaderList[0], nil
+		}
+		var err error
+		composite, err = uploaders.NewMirrorUploader(sg.logger, 0)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		uploaderList = uploaderList[1:]
+	}
+
+	if len(uploaderList) == 0 {
+		return nil, errors.New(fmt.Sprintf("uploader %s should be followed by uploaders it uses", composite.Name()))
+	}
+	err := composite.AddUploaders(uploaderList...)
+	if err != nil {
+		return nil, err
+	}
+	return composite, nil
+}
+
+// uploadedFile returns reader of the uploaded file without reading it into
+// memory. It is either "file" part of multipart form or the whole request body
+func uploadedFile(req *http.Request) (io.ReadCloser, error) {
+	mr, err := req.MultipartReader()
+	if err == http.ErrNotMultipart {
+		return req.Body, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("there is no \"file\" field in multipart form")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// responseWriter keeps track of whether anything was already sent to client
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.written = true
+	return rw.ResponseWriter.Write(p)
+}
+
+// checkParamNames checks that names given to params in pipeline spec match
+// schema of component
+func checkParamNames(component common.Component, params []models.ParamValue) error {
+	describable, ok := component.(common.Describable)
+	if !ok {
+		return nil
+	}
+	schema := describable.Schema()
+	if schema.AnyParams || len(schema.Params) == 0 {
+		return nil
+	}
+	for i, param := range params {
+		expected := schema.Params[min(i, len(schema.Params)-1)].Name
+		if param.Name != "" && param.Name != expected {
+			return errors.New(fmt.Sprintf("component %s: param %d should be %s, not %s", schema.Name, i, expected, param.Name))
+		}
+	}
+	return nil
+}
+
+// isYAML tells whether request body is YAML rather than JSON
+func isYAML(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Content-Type"), "yaml")
+}
+
 func enableCors(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 }
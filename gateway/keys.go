@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/models"
+	"github.com/takahawk/shadownet/transformers"
+)
+
+// VerifiedKeyHeader is response header containing base64'd public key that
+// content signature was verified with. There is one header per signature
+const VerifiedKeyHeader = "X-Shadownet-Verified-Key"
+
+// VerifiedKeyNameHeader is response header containing name of verified key
+// if it is known to gateway keyring
+const VerifiedKeyNameHeader = "X-Shadownet-Verified-Key-Name"
+
+func (sg *shadowGateway) handleListKeysRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	keys, err := sg.storage.ListKeys()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+
+	// private keys never leave the gateway
+	for _, key := range keys {
+		key.PrivateKey = nil
+	}
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+
+	w.Write(data)
+}
+
+// handleAddKeyRequest adds key pair to gateway keyring. If private key is not
+// given in request, new key pair is generated. Public key is returned back
+func (sg *shadowGateway) handleAddKeyRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	b, err := io.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	var key models.Key
+	err = json.Unmarshal(b, &key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		sg.logger.Errorf("Error unmarshaling key: %+v", err)
+		return
+	}
+
+	err = completeKey(&key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+
+	err = sg.storage.SaveKey(&key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sg.logger.Infof("Key with name \"%s\" successfully added", key.Name)
+	key.PrivateKey = nil
+	data, err := json.Marshal(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	w.Write(data)
+}
+
+func (sg *shadowGateway) handleDeleteKeyRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	vars := mux.Vars(req)
+	keyName := vars["keyName"]
+
+	err := sg.storage.DeleteKey(keyName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sg.logger.Infof("Key with name \"%s\" successfully deleted\n", keyName)
+	fmt.Fprintf(w, "Key with name \"%s\" successfully deleted\n", keyName)
+}
+
+// provideKeys sets private keys from keyring to all components that need them
+func (sg *shadowGateway) provideKeys(components []common.Component) error {
+	for _, component := range components {
+		holder, ok := component.(transformers.KeyHolder)
+		if !ok {
+			continue
+		}
+		for _, publicKey := range holder.PublicKeys() {
+			key, err := sg.storage.LoadKeyByPublicKey(publicKey)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			err = holder.SetPrivateKey(key.PublicKey, key.PrivateKey)
+			if err != nil {
+				return err
+			}
+			sg.logger.Infof("Using key \"%s\" for %s", key.Name, holder.Name())
+			break
+		}
+	}
+	return nil
+}
+
+// setVerifiedHeaders reports keys that content was verified with
+func (sg *shadowGateway) setVerifiedHeaders(w http.ResponseWriter, components []common.Component) {
+	for _, component := range components {
+		verifier, ok := component.(transformers.Verifier)
+		if !ok || verifier.VerifiedBy() == nil {
+			continue
+		}
+		publicKey := verifier.VerifiedBy()
+		w.Header().Add(VerifiedKeyHeader, base64.StdEncoding.EncodeToString(publicKey))
+		key, err := sg.storage.LoadKeyByPublicKey(publicKey)
+		if err == nil {
+			w.Header().Add(VerifiedKeyNameHeader, key.Name)
+		}
+	}
+}
+
+// completeKey generates new private key if it is not set and derives public
+// key from it
+func completeKey(key *models.Key) error {
+	switch key.Type {
+	case models.KeyTypeX25519:
+		var privateKey *ecdh.PrivateKey
+		var err error
+		if len(key.PrivateKey) == 0 {
+			privateKey, err = ecdh.X25519().GenerateKey(rand.Reader)
+		} else {
+			privateKey, err = ecdh.X25519().NewPrivateKey(key.PrivateKey)
+		}
+		if err != nil {
+			return err
+		}
+		key.PrivateKey = privateKey.Bytes()
+		key.PublicKey = privateKey.PublicKey().Bytes()
+	case models.KeyTypeEd25519:
+		var privateKey ed25519.PrivateKey
+		switch len(key.PrivateKey) {
+		case 0:
+			_, generated, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				return err
+			}
+			privateKey = generated
+		case ed25519.SeedSize:
+			privateKey = ed25519.NewKeyFromSeed(key.PrivateKey)
+		case ed25519.PrivateKeySize:
+			privateKey = ed25519.NewKeyFromSeed(key.PrivateKey[:ed25519.SeedSize])
+		default:
+			return errors.New("invalid ed25519 private key length")
+		}
+		key.PrivateKey = privateKey
+		key.PublicKey = privateKey.Public().(ed25519.PublicKey)
+	default:
+		return errors.New(fmt.Sprintf("unknown key type: %s", key.Type))
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/takahawk/shadownet/models"
+	"github.com/takahawk/shadownet/oauth"
+)
+
+// OAuthStateLifetime is time user has to grant access after authorization
+// is started
+const OAuthStateLifetime = 10 * time.Minute
+
+// oauthState is authorization started by gateway and waiting for callback.
+// Account is saved only after callback succeeds, so that tokens of linked
+// account are kept until the new ones are obtained
+type oauthState struct {
+	account     models.OAuthAccount
+	redirectURI string
+	expiry      time.Time
+}
+
+// handleAuthorizeRequest returns URL of provider's consent page to link new
+// account with client credentials given in request. Account is saved once
+// access is granted. Names of already linked accounts are rejected, since
+// they are re-linked with GET request
+func (sg *shadowGateway) handleAuthorizeRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	provider, err := oauth.ProviderByName(mux.Vars(req)["provider"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	b, err := io.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	var account models.OAuthAccount
+	err = json.Unmarshal(b, &account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		sg.logger.Errorf("Error unmarshaling account: %+v", err)
+		return
+	}
+	if account.Name == "" {
+		http.Error(w, "name of account is required", http.StatusBadRequest)
+		return
+	}
+	if account.ClientID == "" || account.ClientSecret == "" {
+		http.Error(w, "client id and client secret are required", http.StatusBadRequest)
+		return
+	}
+	_, err = sg.storage.LoadAccount(account.Name)
+	if err == nil {
+		http.Error(w, fmt.Sprintf("account with name \"%s\" already exists, GET /oauth/%s/authorize?account=%s re-links it", account.Name, provider.Name, account.Name), http.StatusConflict)
+		return
+	}
+	if err != sql.ErrNoRows {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// tokens are only obtained from provider
+	account = models.OAuthAccount{
+		Name:         account.Name,
+		Provider:     provider.Name,
+		ClientID:     account.ClientID,
+		ClientSecret: account.ClientSecret,
+	}
+
+	authorizeUrl, err := sg.startAuthorization(req, provider, &account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	response := make(map[string]string)
+	response["authorizeUrl"] = authorizeUrl
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	w.Write(responseJson)
+}
+
+// handleAuthorizeRedirectRequest redirects to provider's consent page to
+// re-link account that is already saved. Its tokens are replaced only after
+// callback succeeds
+func (sg *shadowGateway) handleAuthorizeRedirectRequest(w http.ResponseWriter, req *http.Request) {
+	provider, err := oauth.ProviderByName(mux.Vars(req)["provider"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	account, err := sg.storage.LoadAccount(req.URL.Query().Get("account"))
+	if err != nil || account.Provider != provider.Name {
+		http.Error(w, "account is not found", http.StatusNotFound)
+		return
+	}
+
+	authorizeUrl, err := sg.startAuthorization(req, provider, account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	http.Redirect(w, req, authorizeUrl, http.StatusFound)
+}
+
+// handleCallbackRequest gets tokens for authorization code provider
+// redirected user back with and saves account along with them
+func (sg *shadowGateway) handleCallbackRequest(w http.ResponseWriter, req *http.Request) {
+	provider, err := oauth.ProviderByName(mux.Vars(req)["provider"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	query := req.URL.Query()
+	state, ok := sg.popOAuthState(query.Get("state"))
+	if !ok {
+		http.Error(w, "unknown or expired authorization state", http.StatusBadRequest)
+		return
+	}
+	if query.Get("error") != "" {
+		http.Error(w, fmt.Sprintf("authorization failed: %s", query.Get("error")), http.StatusBadRequest)
+		return
+	}
+
+	account := state.account
+	token, err := oauth.Exchange(provider, account.ClientID, account.ClientSecret, state.redirectURI, query.Get("code"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	account.AccessToken = token.AccessToken
+	account.RefreshToken = token.RefreshToken
+	account.Expiry = token.Expiry
+	err = sg.storage.SaveAccount(&account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sg.logger.Infof("Account with name \"%s\" successfully linked", account.Name)
+	fmt.Fprintf(w, "Account with name \"%s\" successfully linked\n", account.Name)
+}
+
+// startAuthorization remembers authorization state along with account to
+// save on callback and returns URL of provider's consent page
+func (sg *shadowGateway) startAuthorization(req *http.Request, provider *oauth.Provider, account *models.OAuthAccount) (string, error) {
+	stateBytes := make([]byte, 16)
+	_, err := rand.Read(stateBytes)
+	if err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(stateBytes)
+	redirectURI, err := sg.callbackURL(req, provider)
+	if err != nil {
+		return "", err
+	}
+
+	sg.oauthMutex.Lock()
+	defer sg.oauthMutex.Unlock()
+	now := time.Now()
+	for key, pending := range sg.oauthStates {
+		if now.After(pending.expiry) {
+			delete(sg.oauthStates, key)
+		}
+	}
+	sg.oauthStates[state] = oauthState{
+		account:     *account,
+		redirectURI: redirectURI,
+		expiry:      now.Add(OAuthStateLifetime),
+	}
+
+	return oauth.AuthCodeURL(provider, account.ClientID, redirectURI, state), nil
+}
+
+// popOAuthState returns pending authorization state. Each state can be used
+// only once
+func (sg *shadowGateway) popOAuthState(key string) (oauthState, bool) {
+	sg.oauthMutex.Lock()
+	defer sg.oauthMutex.Unlock()
+	state, ok := sg.oauthStates[key]
+	delete(sg.oauthStates, key)
+	if !ok || time.Now().After(state.expiry) {
+		return oauthState{}, false
+	}
+	return state, true
+}
+
+// callbackURL returns URL of gateway callback endpoint for a given provider.
+// It is made from external URL of gateway if it is set, otherwise from request
+// as it is seen by client
+func (sg *shadowGateway) callbackURL(req *http.Request, provider *oauth.Provider) (string, error) {
+	if sg.externalURL != "" {
+		return fmt.Sprintf("%s/oauth/%s/callback", sg.externalURL, provider.Name), nil
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := req.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		if forwarded != "http" && forwarded != "https" {
+			return "", errors.New(fmt.Sprintf("unsupported forwarded scheme: %s", forwarded))
+		}
+		scheme = forwarded
+	}
+	return fmt.Sprintf("%s://%s/oauth/%s/callback", scheme, req.Host, provider.Name), nil
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/oauth"
+)
+
+func TestCallbackURL(t *testing.T) {
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	provider, err := oauth.ProviderByName("dropbox")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	external, err := NewShadowGatewayWithExternalURL(log, nil, nil, "https://shadownet.example.com/")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	tests := []struct {
+		name     string
+		gateway  ShadownetGateway
+		proto    string
+		expected string
+		fails    bool
+	}{
+		{"request", NewShadowGateway(log, nil, nil), "", "http://gateway.local/oauth/dropbox/callback", false},
+		{"forwarded https", NewShadowGateway(log, nil, nil), "https", "https://gateway.local/oauth/dropbox/callback", false},
+		{"forwarded unknown scheme", NewShadowGateway(log, nil, nil), "javascript", "", true},
+		{"external URL", external, "javascript", "https://shadownet.example.com/oauth/dropbox/callback", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://gateway.local/oauth/dropbox/authorize", nil)
+			if test.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", test.proto)
+			}
+			callbackURL, err := test.gateway.(*shadowGateway).callbackURL(req, provider)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %s", callbackURL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if callbackURL != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, callbackURL)
+			}
+		})
+	}
+
+	for _, externalURL := range []string{"shadownet.example.com", "ftp://shadownet.example.com", "https://"} {
+		_, err := NewShadowGatewayWithExternalURL(log, nil, nil, externalURL)
+		if err == nil {
+			t.Fatalf("external URL %s should be rejected", externalURL)
+		}
+	}
+}
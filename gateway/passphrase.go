@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/transformers"
+)
+
+// PassphraseFormField is name of form field used to submit passphrase for
+// content protected with passphrase encryptor
+const PassphraseFormField = "passphrase"
+
+const passphraseFormTemplate = `<!DOCTYPE html>
+<html>
+<head><title>ShadowNet: passphrase required</title></head>
+<body>
+<p>%s</p>
+<form method="post">
+<input type="password" name="` + PassphraseFormField + `" autofocus>
+<input type="submit" value="Open">
+</form>
+</body>
+</html>
+`
+
+// providePassphrase sets passphrase submitted with request to all components
+// that require it. If some of them require passphrase but it wasn't
+// submitted, form asking for it is written as response and false is returned
+func (sg *shadowGateway) providePassphrase(w http.ResponseWriter, req *http.Request, components []common.Component) bool {
+	// passphrase is accepted only from request body, so that it doesn't
+	// get into access logs and browser history
+	passphrase := req.PostFormValue(PassphraseFormField)
+	for _, component := range components {
+		pt, ok := component.(transformers.PassphraseTransformer)
+		if !ok || pt.HasPassphrase() {
+			continue
+		}
+		if passphrase == "" {
+			writePassphraseForm(w, "This content is protected with passphrase")
+			return false
+		}
+		pt.SetPassphrase([]byte(passphrase))
+	}
+	return true
+}
+
+func writePassphraseForm(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusUnauthorized)
+	fmt.Fprintf(w, passphraseFormTemplate, html.EscapeString(message))
+}
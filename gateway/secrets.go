@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/takahawk/shadownet/models"
+)
+
+// handleListSecretsRequest returns names of secrets. Values never leave the
+// gateway
+func (sg *shadowGateway) handleListSecretsRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	secrets, err := sg.storage.ListSecrets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+
+	for _, secret := range secrets {
+		secret.Value = ""
+	}
+
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+
+	w.Write(data)
+}
+
+// handleSaveSecretRequest adds secret or replaces value of existing one
+func (sg *shadowGateway) handleSaveSecretRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	b, err := io.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		sg.logger.Errorf("%+v", err)
+		return
+	}
+	var secret models.Secret
+	err = json.Unmarshal(b, &secret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		sg.logger.Errorf("Error unmarshaling secret: %+v", err)
+		return
+	}
+	if secret.Name == "" {
+		http.Error(w, "name of secret is required", http.StatusBadRequest)
+		return
+	}
+
+	err = sg.storage.SaveSecret(&secret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sg.logger.Infof("Secret with name \"%s\" successfully saved", secret.Name)
+	fmt.Fprintf(w, "Secret with name \"%s\" successfully saved\n", secret.Name)
+}
+
+func (sg *shadowGateway) handleDeleteSecretRequest(w http.ResponseWriter, req *http.Request) {
+	enableCors(w)
+	vars := mux.Vars(req)
+	secretName := vars["secretName"]
+
+	err := sg.storage.DeleteSecret(secretName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sg.logger.Infof("Secret with name \"%s\" successfully deleted\n", secretName)
+	fmt.Fprintf(w, "Secret with name \"%s\" successfully deleted\n", secretName)
+}
+
+// lookupSecret returns value of secret pipeline spec refers to
+func (sg *shadowGateway) lookupSecret(name string) (string, error) {
+	secret, err := sg.storage.LoadSecret(name)
+	if err == sql.ErrNoRows {
+		return "", errors.New(fmt.Sprintf("there is no secret with name %s", name))
+	}
+	if err != nil {
+		return "", err
+	}
+	return secret.Value, nil
+}
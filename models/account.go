@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OAuthAccount is storage service account linked to gateway with OAuth 2.0.
+// Pipeline specifications refer to it by name instead of keeping tokens
+type OAuthAccount struct {
+	Name         string    `json:"name"`
+	Provider     string    `json:"provider"`
+	ClientID     string    `json:"clientId"`
+	ClientSecret string    `json:"clientSecret,omitempty"`
+	AccessToken  string    `json:"accessToken,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// KeyTypeX25519 is type of X25519 key pair used to encrypt data to recipients
+const KeyTypeX25519 = "x25519"
+
+// KeyTypeEd25519 is type of Ed25519 key pair used to sign data
+const KeyTypeEd25519 = "ed25519"
+
+// Key is key pair stored in gateway keyring
+type Key struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	PublicKey  []byte `json:"publicKey"`
+	PrivateKey []byte `json:"privateKey,omitempty"`
+}
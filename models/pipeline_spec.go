@@ -1,11 +1,255 @@
 package models
 
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PipelineSpecVersion is version of pipeline specification format. Specs
+// without version are legacy ones (all params are strings with a single
+// isParamsBase64d flag per component) and are upgraded on parsing
+const PipelineSpecVersion = 2
+
+// ParamValueType tells how value of param is turned into bytes passed to
+// component
+type ParamValueType string
+
+const (
+	// ParamValueString is text passed as is. Secret references in it are
+	// replaced with secret values. It is the default type
+	ParamValueString ParamValueType = "string"
+	// ParamValueBase64 is binary data in standard base64. Value can be
+	// secret reference, so that binary secret is stored in base64
+	ParamValueBase64 ParamValueType = "base64"
+	// ParamValueHex is binary data in hex. Value can be secret reference,
+	// so that binary secret is stored in hex
+	ParamValueHex ParamValueType = "hex"
+	// ParamValueInt is integer in decimal form
+	ParamValueInt ParamValueType = "int"
+	// ParamValueSecret is name of secret stored in gateway. Secret is passed
+	// as is, so it should be text
+	ParamValueSecret ParamValueType = "secret"
+)
+
+// secretRefPattern matches references to secrets in string params, i.e.
+// ${secret:dropbox-main}
+var secretRefPattern = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+
 // PipelineSpec is specification of pipeline with name and its components
 type PipelineSpec struct {
-	Name       string `json:"name"`
-	Components []struct {
-		Name            string   `json:"name"`
-		Params          []string `json:"params"`
-		IsParamsBase64d bool     `json:"isParamsBase64d"`
-	} `json:"components"`
+	Version    int             `json:"version" yaml:"version"`
+	Name       string          `json:"name" yaml:"name"`
+	Components []ComponentSpec `json:"components" yaml:"components"`
+}
+
+// ComponentSpec is component of pipeline with its params in order they are
+// passed to component
+type ComponentSpec struct {
+	Name   string       `json:"name" yaml:"name"`
+	Params []ParamValue `json:"params" yaml:"params"`
+}
+
+// ParamValue is typed value of component param. Name is optional and is
+// checked against component schema if it is given
+type ParamValue struct {
+	Name  string         `json:"name,omitempty" yaml:"name,omitempty"`
+	Type  ParamValueType `json:"type,omitempty" yaml:"type,omitempty"`
+	Value string         `json:"value" yaml:"value"`
+}
+
+// SecretLookup returns value of secret with a given name
+type SecretLookup func(name string) (string, error)
+
+// ParsePipelineSpec parses pipeline specification either in JSON (both
+// current and legacy format) or in YAML and validates it
+func ParsePipelineSpec(data []byte, isYAML bool) (*PipelineSpec, error) {
+	var spec PipelineSpec
+	var err error
+	if isYAML {
+		err = yaml.Unmarshal(data, &spec)
+	} else {
+		err = json.Unmarshal(data, &spec)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// UnmarshalJSON parses pipeline spec upgrading legacy one
+func (ps *PipelineSpec) UnmarshalJSON(data []byte) error {
+	type pipelineSpec PipelineSpec
+	err := json.Unmarshal(data, (*pipelineSpec)(ps))
+	if err != nil {
+		return err
+	}
+	if ps.Version == 0 {
+		ps.Version = PipelineSpecVersion
+	}
+	return nil
+}
+
+// UnmarshalYAML parses pipeline spec. Version can be omitted
+func (ps *PipelineSpec) UnmarshalYAML(node *yaml.Node) error {
+	type pipelineSpec PipelineSpec
+	err := node.Decode((*pipelineSpec)(ps))
+	if err != nil {
+		return err
+	}
+	if ps.Version == 0 {
+		ps.Version = PipelineSpecVersion
+	}
+	return nil
+}
+
+// UnmarshalJSON parses component spec. Legacy isParamsBase64d flag makes
+// params given as plain strings base64 ones
+func (cs *ComponentSpec) UnmarshalJSON(data []byte) error {
+	var spec struct {
+		Name            string       `json:"name"`
+		Params          []ParamValue `json:"params"`
+		IsParamsBase64d bool         `json:"isParamsBase64d"`
+	}
+	err := json.Unmarshal(data, &spec)
+	if err != nil {
+		return err
+	}
+	cs.Name = spec.Name
+	cs.Params = spec.Params
+	if spec.IsParamsBase64d {
+		for i := range cs.Params {
+			if cs.Params[i].Type == "" {
+				cs.Params[i].Type = ParamValueBase64
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON parses param either as an object or as plain string
+func (pv *ParamValue) UnmarshalJSON(data []byte) error {
+	var value string
+	if json.Unmarshal(data, &value) == nil {
+		*pv = ParamValue{Value: value}
+		return nil
+	}
+	type paramValue ParamValue
+	return json.Unmarshal(data, (*paramValue)(pv))
+}
+
+// UnmarshalYAML parses param either as a mapping or as plain scalar
+func (pv *ParamValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*pv = ParamValue{Value: node.Value}
+		return nil
+	}
+	type paramValue ParamValue
+	return node.Decode((*paramValue)(pv))
+}
+
+// MarshalJSON writes param always with its type, so that stored specs are
+// explicit
+func (pv ParamValue) MarshalJSON() ([]byte, error) {
+	type paramValue ParamValue
+	if pv.Type == "" {
+		pv.Type = ParamValueString
+	}
+	return json.Marshal(paramValue(pv))
+}
+
+// Validate checks that spec has supported version and all params have known
+// types and well-formed values. Secrets are not checked for existence
+func (ps *PipelineSpec) Validate() error {
+	if ps.Version > PipelineSpecVersion {
+		return errors.New(fmt.Sprintf("unsupported pipeline spec version: %d", ps.Version))
+	}
+	if ps.Name == "" {
+		return errors.New("empty name of pipeline specification")
+	}
+	if len(ps.Components) == 0 {
+		return errors.New("pipeline specification without components")
+	}
+	for i, component := range ps.Components {
+		if component.Name == "" {
+			return errors.New(fmt.Sprintf("component %d has no name", i))
+		}
+		for j, param := range component.Params {
+			_, err := param.Decode(func(name string) (string, error) {
+				return "", nil
+			})
+			if err != nil {
+				return errors.New(fmt.Sprintf("component %s: invalid param %d: %+v", component.Name, j, err))
+			}
+		}
+	}
+	return nil
+}
+
+// Decode returns bytes of param value passed to component. Secret
+// references in string, base64 and hex values are replaced before decoding,
+// so binary secrets (i.e. keys) can be stored in encoded form. Secrets are
+// taken with lookup
+func (pv ParamValue) Decode(lookup SecretLookup) ([]byte, error) {
+	switch pv.Type {
+	case "", ParamValueString:
+		value, err := substituteSecrets(pv.Value, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
+	case ParamValueBase64:
+		value, err := substituteSecrets(pv.Value, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(value)
+	case ParamValueHex:
+		value, err := substituteSecrets(pv.Value, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return hex.DecodeString(value)
+	case ParamValueInt:
+		_, err := strconv.Atoi(pv.Value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("not an integer: %s", pv.Value))
+		}
+		return []byte(pv.Value), nil
+	case ParamValueSecret:
+		if pv.Value == "" {
+			return nil, errors.New("empty secret name")
+		}
+		secret, err := lookup(pv.Value)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(secret), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown param type: %s", pv.Type))
+	}
+}
+
+// substituteSecrets replaces secret references in value with secret values
+func substituteSecrets(value string, lookup SecretLookup) (string, error) {
+	var lookupErr error
+	value = secretRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		secret, err := lookup(secretRefPattern.FindStringSubmatch(ref)[1])
+		if err != nil && lookupErr == nil {
+			lookupErr = err
+		}
+		return secret
+	})
+	return value, lookupErr
 }
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testSecrets = map[string]string{
+	"token":   "s3cr3t",
+	"aes-key": base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")),
+	"hex-key": "00ff10",
+}
+
+func lookupTestSecret(name string) (string, error) {
+	secret, ok := testSecrets[name]
+	if !ok {
+		return "", errors.New("unknown secret " + name)
+	}
+	return secret, nil
+}
+
+func TestParseLegacyPipelineSpec(t *testing.T) {
+	legacyBase64 := "c2hhZG93bmV0AAE="
+	data := []byte(`{"name":"legacy","components":[
+		{"name":"aes","params":["` + legacyBase64 + `"],"isParamsBase64d":true},
+		{"name":"pastebin","params":["dev-key"]}
+	]}`)
+	spec, err := ParsePipelineSpec(data, false)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if spec.Version != PipelineSpecVersion {
+		t.Fatalf("legacy spec should be upgraded to version %d, got %d", PipelineSpecVersion, spec.Version)
+	}
+
+	param := spec.Components[0].Params[0]
+	if param.Type != ParamValueBase64 {
+		t.Fatalf("param of isParamsBase64d component should be base64, got %q", param.Type)
+	}
+	decoded, err := param.Decode(lookupTestSecret)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	// the same bytes legacy format gave
+	expected, _ := base64.StdEncoding.DecodeString(legacyBase64)
+	if !bytes.Equal(decoded, expected) {
+		t.Fatalf("expected %v, got %v", expected, decoded)
+	}
+
+	param = spec.Components[1].Params[0]
+	if param.Type != "" {
+		t.Fatalf("param of plain component should be string, got %q", param.Type)
+	}
+
+	// upgraded spec is stored with explicit types and without legacy flag
+	upgraded, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if strings.Contains(string(upgraded), "isParamsBase64d") || !strings.Contains(string(upgraded), `"type":"base64"`) {
+		t.Fatalf("unexpected upgraded spec: %s", upgraded)
+	}
+	reparsed, err := ParsePipelineSpec(upgraded, false)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	decoded, _ = reparsed.Components[0].Params[0].Decode(lookupTestSecret)
+	if !bytes.Equal(decoded, expected) {
+		t.Fatalf("upgraded spec gives %v instead of %v", decoded, expected)
+	}
+}
+
+func TestParseYAMLPipelineSpec(t *testing.T) {
+	data := []byte(`
+name: yaml
+components:
+  - name: webdav
+    params:
+      - https://example.com/dav/
+      - name: username
+        value: user
+      - name: password
+        type: secret
+        value: token
+`)
+	spec, err := ParsePipelineSpec(data, true)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if spec.Version != PipelineSpecVersion {
+		t.Fatalf("expected version %d, got %d", PipelineSpecVersion, spec.Version)
+	}
+	expected := []ParamValue{
+		{Value: "https://example.com/dav/"},
+		{Name: "username", Value: "user"},
+		{Name: "password", Type: ParamValueSecret, Value: "token"},
+	}
+	params := spec.Components[0].Params
+	if len(params) != len(expected) {
+		t.Fatalf("expected %d params, got %d", len(expected), len(params))
+	}
+	for i := range expected {
+		if params[i] != expected[i] {
+			t.Fatalf("param %d: expected %+v, got %+v", i, expected[i], params[i])
+		}
+	}
+}
+
+func TestDecodeParamValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    ParamValue
+		expected []byte
+		fails    bool
+	}{
+		{"string", ParamValue{Value: "plain"}, []byte("plain"), false},
+		{"explicit string", ParamValue{Type: ParamValueString, Value: "plain"}, []byte("plain"), false},
+		{"secret in string", ParamValue{Value: "Bearer ${secret:token}"}, []byte("Bearer s3cr3t"), false},
+		{"unknown secret in string", ParamValue{Value: "${secret:missing}"}, nil, true},
+		{"base64", ParamValue{Type: ParamValueBase64, Value: "AAEC"}, []byte{0, 1, 2}, false},
+		{"invalid base64", ParamValue{Type: ParamValueBase64, Value: "not base64!"}, nil, true},
+		{"secret in base64", ParamValue{Type: ParamValueBase64, Value: "${secret:aes-key}"}, []byte("0123456789abcdef"), false},
+		{"hex", ParamValue{Type: ParamValueHex, Value: "0a0b"}, []byte{10, 11}, false},
+		{"invalid hex", ParamValue{Type: ParamValueHex, Value: "xyz"}, nil, true},
+		{"secret in hex", ParamValue{Type: ParamValueHex, Value: "${secret:hex-key}"}, []byte{0, 255, 16}, false},
+		{"int", ParamValue{Type: ParamValueInt, Value: "42"}, []byte("42"), false},
+		{"negative int", ParamValue{Type: ParamValueInt, Value: "-1"}, []byte("-1"), false},
+		{"invalid int", ParamValue{Type: ParamValueInt, Value: "4.2"}, nil, true},
+		{"secret", ParamValue{Type: ParamValueSecret, Value: "token"}, []byte("s3cr3t"), false},
+		// secret type takes name, not reference
+		{"secret reference as name", ParamValue{Type: ParamValueSecret, Value: "${secret:token}"}, nil, true},
+		{"empty secret name", ParamValue{Type: ParamValueSecret}, nil, true},
+		{"unknown type", ParamValue{Type: "float", Value: "1"}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := test.param.Decode(lookupTestSecret)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %q", decoded)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if !bytes.Equal(decoded, test.expected) {
+				t.Fatalf("expected %q, got %q", test.expected, decoded)
+			}
+		})
+	}
+}
+
+func TestValidatePipelineSpec(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		fails bool
+	}{
+		{"valid", `{"version":2,"name":"p","components":[{"name":"web","params":[{"type":"int","value":"1"}]}]}`, false},
+		{"unknown secret is not checked", `{"name":"p","components":[{"name":"web","params":["${secret:missing}"]}]}`, false},
+		{"future version", `{"version":3,"name":"p","components":[{"name":"web"}]}`, true},
+		{"no name", `{"components":[{"name":"web"}]}`, true},
+		{"no components", `{"name":"p"}`, true},
+		{"component without name", `{"name":"p","components":[{}]}`, true},
+		{"invalid param", `{"name":"p","components":[{"name":"web","params":[{"type":"hex","value":"x"}]}]}`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParsePipelineSpec([]byte(test.data), false)
+			if test.fails && err == nil {
+				t.Fatal("expected error")
+			}
+			if !test.fails && err != nil {
+				t.Fatalf("%+v", err)
+			}
+		})
+	}
+}
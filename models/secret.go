@@ -0,0 +1,8 @@
+package models
+
+// Secret is named value (token, password etc.) stored in gateway. Pipeline
+// specifications refer to it with ${secret:name} instead of keeping it inline
+type Secret struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
@@ -0,0 +1,130 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token is result of authorization code or refresh token exchange
+type Token struct {
+	AccessToken string
+	// RefreshToken is empty if provider doesn't issue new one on refresh
+	RefreshToken string
+	// Expiry is zero if access token doesn't expire
+	Expiry time.Time
+}
+
+type tokenResponseBody struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// AuthCodeURL returns URL of provider's consent page. After user grants
+// access, provider redirects back to redirectURI with authorization code and
+// a given state
+func AuthCodeURL(provider *Provider, clientID, redirectURI, state string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", clientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("state", state)
+	if len(provider.Scopes) != 0 {
+		query.Set("scope", strings.Join(provider.Scopes, " "))
+	}
+	for name, value := range provider.AuthParams {
+		query.Set(name, value)
+	}
+	return provider.AuthURL + "?" + query.Encode()
+}
+
+// Exchange exchanges authorization code for access and refresh tokens
+func Exchange(provider *Provider, clientID, clientSecret, redirectURI, code string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	return requestToken(provider, clientID, clientSecret, form)
+}
+
+// Refresh returns new access token for a given refresh token
+func Refresh(provider *Provider, clientID, clientSecret, refreshToken string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	return requestToken(provider, clientID, clientSecret, form)
+}
+
+// Revoke revokes a given token, so that it can't be used anymore. Revoking
+// refresh token revokes access tokens issued for it as well
+func Revoke(provider *Provider, token string) error {
+	var r *http.Request
+	var err error
+	if provider.RevokeWithBearer {
+		r, err = http.NewRequest(http.MethodPost, provider.RevokeURL, nil)
+		if err == nil {
+			r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		}
+	} else {
+		form := url.Values{}
+		form.Set("token", token)
+		r, err = http.NewRequest(http.MethodPost, provider.RevokeURL, strings.NewReader(form.Encode()))
+		if err == nil {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("revoke request failed with status code: %d", rsp.StatusCode))
+	}
+	return nil
+}
+
+func requestToken(provider *Provider, clientID, clientSecret string, form url.Values) (*Token, error) {
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	rsp, err := http.PostForm(provider.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var tokenRsp tokenResponseBody
+	err = json.Unmarshal(body, &tokenRsp)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid token response with status code %d: %s", rsp.StatusCode, string(body)))
+	}
+	if rsp.StatusCode != http.StatusOK || tokenRsp.Error != "" || tokenRsp.AccessToken == "" {
+		return nil, errors.New(fmt.Sprintf("token request failed with status code %d: %s %s",
+			rsp.StatusCode, tokenRsp.Error, tokenRsp.ErrorDescription))
+	}
+
+	token := &Token{
+		AccessToken:  tokenRsp.AccessToken,
+		RefreshToken: tokenRsp.RefreshToken,
+	}
+	if tokenRsp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenRsp.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
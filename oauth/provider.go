@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Provider describes OAuth 2.0 authorization server of storage service
+type Provider struct {
+	// Name is name of provider used in gateway endpoints and accounts
+	Name string
+	// AuthURL is URL user is redirected to in order to grant access
+	AuthURL string
+	// TokenURL is URL to exchange authorization code or refresh token for
+	// access token
+	TokenURL string
+	// Scopes are access scopes requested during authorization
+	Scopes []string
+	// AuthParams are additional params of authorization URL that are
+	// required to get refresh token
+	AuthParams map[string]string
+	// RevokeURL is URL to revoke tokens when account is unlinked
+	RevokeURL string
+	// RevokeWithBearer is true if token to be revoked is sent in
+	// Authorization header instead of form
+	RevokeWithBearer bool
+}
+
+// GoogleProviderName is name of Google provider
+const GoogleProviderName = "google"
+
+// DropboxProviderName is name of Dropbox provider
+const DropboxProviderName = "dropbox"
+
+// GoogleProvider is Google OAuth 2.0 provider with access only to files
+// created by ShadowNet in Google Drive
+var GoogleProvider = &Provider{
+	Name:     GoogleProviderName,
+	AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+	TokenURL: "https://oauth2.googleapis.com/token",
+	Scopes:   []string{"https://www.googleapis.com/auth/drive.file"},
+	AuthParams: map[string]string{
+		"access_type": "offline",
+		// refresh token is only returned on consent
+		"prompt": "consent",
+	},
+	RevokeURL: "https://oauth2.googleapis.com/revoke",
+}
+
+// DropboxProvider is Dropbox OAuth 2.0 provider
+var DropboxProvider = &Provider{
+	Name:     DropboxProviderName,
+	AuthURL:  "https://www.dropbox.com/oauth2/authorize",
+	TokenURL: "https://api.dropboxapi.com/oauth2/token",
+	AuthParams: map[string]string{
+		"token_access_type": "offline",
+	},
+	RevokeURL:        "https://api.dropboxapi.com/2/auth/token/revoke",
+	RevokeWithBearer: true,
+}
+
+var providers = map[string]*Provider{
+	GoogleProviderName:  GoogleProvider,
+	DropboxProviderName: DropboxProvider,
+}
+
+// ProviderByName returns known provider with a given name
+func ProviderByName(name string) (*Provider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown OAuth provider: %s", name))
+	}
+	return provider, nil
+}
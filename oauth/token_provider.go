@@ -0,0 +1,110 @@
+package oauth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/models"
+)
+
+// AccessTokenExpiryMargin is time before expiry when access token is
+// considered expired, so that it doesn't expire during request
+const AccessTokenExpiryMargin = time.Minute
+
+// TokenProvider gives access token to components that make authorized
+// requests, so that they don't hold literal tokens which may expire
+type TokenProvider interface {
+	// Token returns valid access token
+	Token() (string, error)
+}
+
+// AccountStore is where accounts token provider takes tokens from and writes
+// refreshed ones back to. Gateway storage implements it
+type AccountStore interface {
+	// LoadAccount returns account with a given name or sql.ErrNoRows if there
+	// is no such account
+	LoadAccount(name string) (*models.OAuthAccount, error)
+	// SaveAccount stores account overwriting existing one with the same name
+	SaveAccount(account *models.OAuthAccount) error
+}
+
+type staticTokenProvider struct {
+	token string
+}
+
+// NewStaticTokenProvider returns provider that always gives the same token
+func NewStaticTokenProvider(token string) TokenProvider {
+	return &staticTokenProvider{
+		token: token,
+	}
+}
+
+// Token returns token given on creation
+func (stp *staticTokenProvider) Token() (string, error) {
+	return stp.token, nil
+}
+
+type accountTokenProvider struct {
+	logger  logger.Logger
+	store   AccountStore
+	account string
+	mutex   sync.Mutex
+}
+
+// NewAccountTokenProvider returns provider that gives access token of account
+// from a given store. Expired token is refreshed and the new one is written
+// back to store
+func NewAccountTokenProvider(logger logger.Logger, store AccountStore, account string) TokenProvider {
+	return &accountTokenProvider{
+		logger:  logger,
+		store:   store,
+		account: account,
+	}
+}
+
+// Token returns access token of account refreshing it if needed
+func (atp *accountTokenProvider) Token() (string, error) {
+	// the same account can be used by several uploads at once and it should
+	// be refreshed only once
+	atp.mutex.Lock()
+	defer atp.mutex.Unlock()
+
+	account, err := atp.store.LoadAccount(atp.account)
+	if err == sql.ErrNoRows {
+		return "", errors.New(fmt.Sprintf("account \"%s\" is not found", atp.account))
+	}
+	if err != nil {
+		return "", err
+	}
+	if account.AccessToken != "" && (account.Expiry.IsZero() || time.Now().Add(AccessTokenExpiryMargin).Before(account.Expiry)) {
+		return account.AccessToken, nil
+	}
+	if account.RefreshToken == "" {
+		return "", errors.New(fmt.Sprintf("account \"%s\" is not authorized", atp.account))
+	}
+
+	provider, err := ProviderByName(account.Provider)
+	if err != nil {
+		return "", err
+	}
+	atp.logger.Infof("Refreshing access token of account \"%s\"", atp.account)
+	token, err := Refresh(provider, account.ClientID, account.ClientSecret, account.RefreshToken)
+	if err != nil {
+		atp.logger.Errorf("%+v", err)
+		return "", err
+	}
+	account.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		account.RefreshToken = token.RefreshToken
+	}
+	account.Expiry = token.Expiry
+	err = atp.store.SaveAccount(account)
+	if err != nil {
+		return "", err
+	}
+	return account.AccessToken, nil
+}
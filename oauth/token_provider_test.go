@@ -0,0 +1,180 @@
+package oauth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/models"
+)
+
+const testProviderName = "test"
+
+// fakeAccountStore keeps accounts in memory
+type fakeAccountStore struct {
+	mutex    sync.Mutex
+	accounts map[string]models.OAuthAccount
+}
+
+func (fas *fakeAccountStore) LoadAccount(name string) (*models.OAuthAccount, error) {
+	fas.mutex.Lock()
+	defer fas.mutex.Unlock()
+	account, ok := fas.accounts[name]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &account, nil
+}
+
+func (fas *fakeAccountStore) SaveAccount(account *models.OAuthAccount) error {
+	fas.mutex.Lock()
+	defer fas.mutex.Unlock()
+	fas.accounts[account.Name] = *account
+	return nil
+}
+
+// fakeTokenEndpoint refreshes tokens of test provider. New refresh token is
+// issued only if rotate is set
+type fakeTokenEndpoint struct {
+	refreshes atomic.Int32
+	rotate    bool
+}
+
+func newFakeTokenEndpoint(t *testing.T, rotate bool) *fakeTokenEndpoint {
+	endpoint := &fakeTokenEndpoint{rotate: rotate}
+	server := httptest.NewServer(endpoint)
+	t.Cleanup(server.Close)
+	providers[testProviderName] = &Provider{Name: testProviderName, TokenURL: server.URL}
+	t.Cleanup(func() { delete(providers, testProviderName) })
+	return endpoint
+}
+
+func (fte *fakeTokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh-token" ||
+		r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(tokenResponseBody{Error: "invalid_grant"})
+		return
+	}
+	// refresh is slow, so that concurrent callers have to wait for it
+	time.Sleep(50 * time.Millisecond)
+	fte.refreshes.Add(1)
+	response := tokenResponseBody{AccessToken: "new-access-token", ExpiresIn: 3600}
+	if fte.rotate {
+		response.RefreshToken = "rotated-refresh-token"
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func newTestAccountStore(expiry time.Time, refreshToken string) *fakeAccountStore {
+	return &fakeAccountStore{accounts: map[string]models.OAuthAccount{
+		"main": {
+			Name:         "main",
+			Provider:     testProviderName,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			AccessToken:  "old-access-token",
+			RefreshToken: refreshToken,
+			Expiry:       expiry,
+		},
+	}}
+}
+
+func TestAccountTokenProvider(t *testing.T) {
+	tests := []struct {
+		name         string
+		expiry       time.Time
+		rotate       bool
+		token        string
+		refreshToken string
+	}{
+		{"valid", time.Now().Add(time.Hour), false, "old-access-token", "refresh-token"},
+		{"never expires", time.Time{}, false, "old-access-token", "refresh-token"},
+		{"expired", time.Now().Add(-time.Hour), false, "new-access-token", "refresh-token"},
+		{"expires within margin", time.Now().Add(AccessTokenExpiryMargin / 2), false, "new-access-token", "refresh-token"},
+		{"refresh token rotated", time.Now().Add(-time.Hour), true, "new-access-token", "rotated-refresh-token"},
+	}
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			endpoint := newFakeTokenEndpoint(t, test.rotate)
+			store := newTestAccountStore(test.expiry, "refresh-token")
+			token, err := NewAccountTokenProvider(log, store, "main").Token()
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if token != test.token {
+				t.Fatalf("expected %s, got %s", test.token, token)
+			}
+
+			account, _ := store.LoadAccount("main")
+			if account.AccessToken != test.token || account.RefreshToken != test.refreshToken {
+				t.Fatalf("unexpected tokens are saved: %s, %s", account.AccessToken, account.RefreshToken)
+			}
+			refreshed := endpoint.refreshes.Load() != 0
+			if refreshed != (test.token == "new-access-token") {
+				t.Fatalf("unexpected number of refreshes: %d", endpoint.refreshes.Load())
+			}
+			if refreshed && time.Until(account.Expiry) < 59*time.Minute {
+				t.Fatalf("expiry of refreshed token is not saved: %v", account.Expiry)
+			}
+		})
+	}
+}
+
+func TestAccountTokenProviderConcurrentRefresh(t *testing.T) {
+	endpoint := newFakeTokenEndpoint(t, false)
+	store := newTestAccountStore(time.Now().Add(-time.Hour), "refresh-token")
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	provider := NewAccountTokenProvider(log, store, "main")
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	errs := make([]error, len(tokens))
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = provider.Token()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range tokens {
+		if errs[i] != nil || tokens[i] != "new-access-token" {
+			t.Fatalf("caller %d got %q (%v)", i, tokens[i], errs[i])
+		}
+	}
+	if endpoint.refreshes.Load() != 1 {
+		t.Fatalf("token should be refreshed once, got %d refreshes", endpoint.refreshes.Load())
+	}
+}
+
+func TestAccountTokenProviderErrors(t *testing.T) {
+	newFakeTokenEndpoint(t, false)
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+
+	_, err := NewAccountTokenProvider(log, newTestAccountStore(time.Time{}, ""), "unknown").Token()
+	if err == nil {
+		t.Fatal("unknown account should be reported")
+	}
+	_, err = NewAccountTokenProvider(log, newTestAccountStore(time.Now().Add(-time.Hour), ""), "main").Token()
+	if err == nil {
+		t.Fatal("expired token without refresh token should be reported")
+	}
+	store := newTestAccountStore(time.Now().Add(-time.Hour), "revoked-refresh-token")
+	_, err = NewAccountTokenProvider(log, store, "main").Token()
+	if err == nil {
+		t.Fatal("failed refresh should be reported")
+	}
+	account, _ := store.LoadAccount("main")
+	if account.AccessToken != "old-access-token" || account.RefreshToken != "revoked-refresh-token" {
+		t.Fatal("account should not be changed after failed refresh")
+	}
+}
@@ -1,6 +1,8 @@
 package pipelines
 
 import (
+	"io"
+
 	"github.com/takahawk/shadownet/common"
 )
 
@@ -15,6 +17,13 @@ type UploadPipeline interface {
 	// uploading it to storage.
 	// ShadowNet URL to access the data is returned afterwards
 	Upload(data []byte) (url string, err error)
+	// UploadStream does the same as Upload but reads data from r passing it
+	// through transformers and uploader as a stream, so that the whole
+	// content is not required to be held in memory
+	UploadStream(r io.Reader) (url string, err error)
+	// Warnings returns failures that were tolerated during upload (i.e.
+	// mirrors that failed while enough of the others succeeded)
+	Warnings() []string
 }
 
 // DownloadPipeline groups components to transform and then upload data to
@@ -28,4 +37,11 @@ type DownloadPipeline interface {
 	// transforming it and returning back. It is supposed to do effectively
 	// the reverse process of what Uploader.Upload have done
 	Download() (data []byte, err error)
+	// DownloadStream does the same as Download but writes the result to w as
+	// it is being downloaded and transformed
+	DownloadStream(w io.Writer) error
+	// DownloadReader chains all the steps and returns reader over the result.
+	// Transformers that can't work on streams (i.e. signature verification)
+	// are already applied when it returns
+	DownloadReader() (io.ReadCloser, error)
 }
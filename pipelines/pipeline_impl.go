@@ -2,10 +2,13 @@ package pipelines
 
 import (
 	"errors"
+	"fmt"
+	"io"
 
 	"github.com/takahawk/shadownet/common"
 	"github.com/takahawk/shadownet/downloaders"
 	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/resolvers"
 	"github.com/takahawk/shadownet/transformers"
 	"github.com/takahawk/shadownet/uploaders"
 	"github.com/takahawk/shadownet/url"
@@ -20,9 +23,10 @@ type uploadPipeline struct {
 
 // NewUploadPipeline returns new empty upload pipeline
 func NewUploadPipeline(logger logger.Logger) UploadPipeline {
+	// resolver isn't used for making URLs, so built-in one is enough
 	return &uploadPipeline{
 		logger:     logger,
-		urlHandler: url.NewUrlHandler(logger),
+		urlHandler: url.NewUrlHandler(logger, resolvers.NewBuiltinResolver(logger)),
 	}
 }
 
@@ -68,7 +72,76 @@ func (up *uploadPipeline) Upload(data []byte) (url string, err error) {
 		}
 	}
 
-	return up.urlHandler.MakeURL(id, up.steps...)
+	return up.makeURL(id)
+}
+
+// UploadStream runs the whole pipeline to upload data read from r returning
+// ShadowNet URL. Transformers are chained as writers feeding the uploader
+func (up *uploadPipeline) UploadStream(r io.Reader) (url string, err error) {
+	if !up.finalized {
+		return "", errors.New("pipeline should be finalized with uploader")
+	}
+
+	uploader := uploaders.NewStreamUploader(up.steps[len(up.steps)-1].(uploaders.Uploader))
+	pr, pw := io.Pipe()
+
+	go func() {
+		var w io.Writer = pw
+		// writers are created from the last transformer to the first one, so
+		// that they should be closed in reverse order to flush everything
+		var writers []io.WriteCloser
+		for i := len(up.steps) - 2; i >= 0; i-- {
+			transformer := transformers.NewStreamTransformer(up.steps[i].(transformers.Transformer))
+			tw, err := transformer.ForwardTransformWriter(w)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			writers = append(writers, tw)
+			w = tw
+		}
+
+		_, err := io.Copy(w, r)
+		for i := len(writers) - 1; i >= 0 && err == nil; i-- {
+			err = writers[i].Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	id, err := uploader.UploadStream(pr)
+	// unblock transforming goroutine in case uploader hasn't read everything
+	pr.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return up.makeURL(id)
+}
+
+// Warnings returns warnings of uploader if it reports them
+func (up *uploadPipeline) Warnings() []string {
+	if !up.finalized {
+		return nil
+	}
+	warner, ok := up.steps[len(up.steps)-1].(uploaders.WarningUploader)
+	if !ok {
+		return nil
+	}
+	return warner.Warnings()
+}
+
+// makeURL returns ShadowNet URL for uploaded id checking its length if
+// uploader requires it
+func (up *uploadPipeline) makeURL(id string) (string, error) {
+	shadowUrl, err := up.urlHandler.MakeURL(id, up.steps...)
+	if err != nil {
+		return "", err
+	}
+	limited, ok := up.steps[len(up.steps)-1].(uploaders.URLLimitedUploader)
+	if ok && len(shadowUrl) > limited.MaxURLLength() {
+		return "", errors.New(fmt.Sprintf("ShadowNet URL is %d characters long which exceeds limit of %d", len(shadowUrl), limited.MaxURLLength()))
+	}
+	return shadowUrl, nil
 }
 
 type downloadPipeline struct {
@@ -85,8 +158,8 @@ func NewDownloadPipeline(logger logger.Logger) DownloadPipeline {
 
 // NewDownloadPipeline constructs from ShadowNet URL new download pipeline
 // that can be used to download and decode data from it
-func NewDownloadPipelineByURL(logger logger.Logger, shadowUrl string) (DownloadPipeline, error) {
-	urlHandler := url.NewUrlHandler(logger)
+func NewDownloadPipelineByURL(logger logger.Logger, resolver resolvers.Resolver, shadowUrl string) (DownloadPipeline, error) {
+	urlHandler := url.NewUrlHandler(logger, resolver)
 	pipeline := NewDownloadPipeline(logger)
 	components, err := urlHandler.GetDownloadComponents(shadowUrl)
 	if err != nil {
@@ -142,3 +215,53 @@ func (dp *downloadPipeline) Download() (data []byte, err error) {
 
 	return data, nil
 }
+
+// DownloadStream runs the whole pipeline to retrieve and transform data
+// writing the final result to w. Transformers are chained as readers on top
+// of the downloaded stream
+func (dp *downloadPipeline) DownloadStream(w io.Writer) error {
+	r, err := dp.DownloadReader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// DownloadReader chains transformers as readers on top of the downloaded
+// stream. Closing returned reader closes the downloaded one
+func (dp *downloadPipeline) DownloadReader() (io.ReadCloser, error) {
+	if len(dp.steps) == 0 {
+		return nil, errors.New("empty download pipeline")
+	}
+
+	var r io.Reader
+	var body io.ReadCloser
+	for _, component := range dp.steps {
+		var err error
+		switch component := component.(type) {
+		case downloaders.Downloader:
+			body, err = downloaders.NewStreamDownloader(component).DownloadStream()
+			r = body
+		case transformers.Transformer:
+			r, err = transformers.NewStreamTransformer(component).ReverseTransformReader(r)
+		}
+
+		if err != nil {
+			if body != nil {
+				body.Close()
+			}
+			return nil, err
+		}
+	}
+
+	return &pipelineReader{Reader: r, Closer: body}, nil
+}
+
+// pipelineReader reads the result of transformations and closes the
+// downloaded stream they are applied to
+type pipelineReader struct {
+	io.Reader
+	io.Closer
+}
@@ -1,66 +1,115 @@
 package resolvers
 
 import (
-	"errors"
-	"fmt"
-
 	"github.com/takahawk/shadownet/downloaders"
 	"github.com/takahawk/shadownet/logger"
 	"github.com/takahawk/shadownet/transformers"
 	"github.com/takahawk/shadownet/uploaders"
 )
 
-type builtinResolver struct {
-	logger          logger.Logger
-	downloaderDict  map[string]func(logger logger.Logger, params ...[]byte) (downloaders.Downloader, error)
-	transformerDict map[string]func(logger logger.Logger, params ...[]byte) (transformers.Transformer, error)
-	uploaderDict    map[string]func(logger logger.Logger, params ...[]byte) (uploaders.Uploader, error)
+// builtinDownloaders are downloaders built-in into ShadowNet directly.
+// Composite ones are registered separately by NewBuiltinResolver
+var builtinDownloaders = []downloaderEntry{
+	{downloaders.WebDownloaderSchema, downloaders.NewWebDownloaderWithParams},
+	{downloaders.PastebinDownloaderSchema, downloaders.NewPastebinDownloaderWithParams},
+	{downloaders.DropboxDownloaderSchema, downloaders.NewDropboxDownloaderWithParams},
+	{downloaders.S3DownloaderSchema, downloaders.NewS3DownloaderWithParams},
+	{downloaders.WebDAVDownloaderSchema, downloaders.NewWebDAVDownloaderWithParams},
+	{downloaders.GistDownloaderSchema, downloaders.NewGistDownloaderWithParams},
+	{downloaders.GDriveDownloaderSchema, downloaders.NewGDriveDownloaderWithParams},
+	{downloaders.IPFSDownloaderSchema, downloaders.NewIPFSDownloaderWithParams},
+	{downloaders.DataDownloaderSchema, downloaders.NewDataDownloaderWithParams},
 }
 
-// NewBuiltinResolver returns new built-in resolver that is created to resolve
-// components built-in into ShadowNet directly (TODO: provide list?)
-func NewBuiltinResolver(log logger.Logger) Resolver {
-	return &builtinResolver{
-		logger: log,
-		downloaderDict: map[string]func(logger logger.Logger, params ...[]byte) (downloaders.Downloader, error){
-			downloaders.WebDownloaderName: downloaders.NewWebDownloaderWithParams,
-		},
-
-		transformerDict: map[string]func(logger logger.Logger, params ...[]byte) (transformers.Transformer, error){
-			transformers.Base64TransformerName: transformers.NewBase64TransformerWithParams,
-			transformers.AESEncryptorName:      transformers.NewAESEncryptorWithParams,
-		},
-
-		uploaderDict: map[string]func(logger logger.Logger, params ...[]byte) (uploaders.Uploader, error){
-			uploaders.PastebinUploaderName: uploaders.NewPastebinUploaderWithParams,
-			uploaders.DropboxUploaderName:  uploaders.NewDropboxUploaderWithParams,
-		},
-	}
+// builtinTransformers are transformers built-in into ShadowNet directly
+var builtinTransformers = []transformerEntry{
+	{transformers.Base64TransformerSchema, transformers.NewBase64TransformerWithParams},
+	{transformers.AESEncryptorSchema, transformers.NewAESEncryptorWithParams},
+	{transformers.AESGCMEncryptorSchema, transformers.NewAESGCMEncryptorWithParams},
+	{transformers.PassphraseEncryptorSchema, transformers.NewPassphraseEncryptorWithParams},
+	{transformers.X25519EncryptorSchema, transformers.NewX25519EncryptorWithParams},
+	{transformers.Ed25519SignerSchema, transformers.NewEd25519SignerWithParams},
+	{transformers.GzipCompressorSchema, transformers.NewGzipCompressorWithParams},
+	{transformers.ZlibCompressorSchema, transformers.NewZlibCompressorWithParams},
+	{transformers.FlateCompressorSchema, transformers.NewFlateCompressorWithParams},
+	{transformers.AutoCompressorSchema, transformers.NewAutoCompressorWithParams},
 }
 
-// ResolveDownloader returns one of built-in downloaders
-func (br *builtinResolver) ResolveDownloader(name string, params ...[]byte) (downloaders.Downloader, error) {
-	if downloaderFactory, ok := br.downloaderDict[name]; ok {
-		return downloaderFactory(br.logger, params...)
-	}
+// builtinUploaders are uploaders built-in into ShadowNet directly
+var builtinUploaders = []uploaderEntry{
+	{uploaders.PastebinUploaderSchema, uploaders.NewPastebinUploaderWithParams},
+	{uploaders.DropboxUploaderSchema, uploaders.NewDropboxUploaderWithParams},
+	{uploaders.ChunkedUploaderSchema, uploaders.NewChunkedUploaderWithParams},
+	{uploaders.ErasureUploaderSchema, uploaders.NewErasureUploaderWithParams},
+	{uploaders.MirrorUploaderSchema, uploaders.NewMirrorUploaderWithParams},
+	{uploaders.S3UploaderSchema, uploaders.NewS3UploaderWithParams},
+	{uploaders.WebDAVUploaderSchema, uploaders.NewWebDAVUploaderWithParams},
+	{uploaders.GistUploaderSchema, uploaders.NewGistUploaderWithParams},
+	{uploaders.GDriveUploaderSchema, uploaders.NewGDriveUploaderWithParams},
+	{uploaders.IPFSUploaderSchema, uploaders.NewIPFSUploaderWithParams},
+	{uploaders.HTTPTemplateUploaderSchema, uploaders.NewHTTPTemplateUploaderWithParams},
+	{uploaders.DataUploaderSchema, uploaders.NewDataUploaderWithParams},
+}
 
-	return nil, errors.New(fmt.Sprintf("there is no built-in downloader with name %s", name))
+// NewBuiltinResolver returns new registry with components built-in into
+// ShadowNet directly and ones registered globally with Register* functions.
+// More components can be registered in it later. File uploader and
+// downloader are not available, since there is no blob directory
+func NewBuiltinResolver(log logger.Logger) Registry {
+	return NewBuiltinResolverWithFileDirectory(log, "")
 }
 
-// ResolveTransformer returns one of built-in transformers
-func (br *builtinResolver) ResolveTransformer(name string, params ...[]byte) (transformers.Transformer, error) {
-	if transformerFactory, ok := br.transformerDict[name]; ok {
-		return transformerFactory(br.logger, params...)
+// NewBuiltinResolverWithFileDirectory returns the same registry as
+// NewBuiltinResolver, but with file uploader and downloader keeping blobs in a
+// given directory. Directory is configuration of gateway, so neither pipeline
+// specs nor URLs refer to it. Empty directory disables file components
+func NewBuiltinResolverWithFileDirectory(log logger.Logger, fileDir string) Registry {
+	r := newRegistry(log, "built-in")
+	for _, entry := range builtinDownloaders {
+		r.downloaders[entry.schema.Name] = entry
+	}
+	for _, entry := range builtinTransformers {
+		r.transformers[entry.schema.Name] = entry
+	}
+	for _, entry := range builtinUploaders {
+		r.uploaders[entry.schema.Name] = entry
 	}
 
-	return nil, errors.New(fmt.Sprintf("there is no built-in transformer with name %s", name))
-}
+	// composite downloaders resolve downloaders of their parts by themselves
+	r.downloaders[downloaders.ChunkedDownloaderName] = downloaderEntry{
+		schema: downloaders.ChunkedDownloaderSchema,
+		factory: func(logger logger.Logger, params ...[]byte) (downloaders.Downloader, error) {
+			return downloaders.NewChunkedDownloaderWithParams(logger, r.rootResolver().ResolveDownloader, params...)
+		},
+	}
+	r.downloaders[downloaders.ErasureDownloaderName] = downloaderEntry{
+		schema: downloaders.ErasureDownloaderSchema,
+		factory: func(logger logger.Logger, params ...[]byte) (downloaders.Downloader, error) {
+			return downloaders.NewErasureDownloaderWithParams(logger, r.rootResolver().ResolveDownloader, params...)
+		},
+	}
+	r.downloaders[downloaders.MirrorDownloaderName] = downloaderEntry{
+		schema: downloaders.MirrorDownloaderSchema,
+		factory: func(logger logger.Logger, params ...[]byte) (downloaders.Downloader, error) {
+			return downloaders.NewMirrorDownloaderWithParams(logger, r.rootResolver().ResolveDownloader, params...)
+		},
+	}
 
-// ResolveUploader returns one of built-in uploaders
-func (br *builtinResolver) ResolveUploader(name string, params ...[]byte) (uploaders.Uploader, error) {
-	if uploaderFactory, ok := br.uploaderDict[name]; ok {
-		return uploaderFactory(br.logger, params...)
+	if fileDir != "" {
+		r.downloaders[downloaders.FileDownloaderName] = downloaderEntry{
+			schema: downloaders.FileDownloaderSchema,
+			factory: func(logger logger.Logger, params ...[]byte) (downloaders.Downloader, error) {
+				return downloaders.NewFileDownloaderWithParams(logger, fileDir, params...)
+			},
+		}
+		r.uploaders[uploaders.FileUploaderName] = uploaderEntry{
+			schema: uploaders.FileUploaderSchema,
+			factory: func(logger logger.Logger, params ...[]byte) (uploaders.Uploader, error) {
+				return uploaders.NewFileUploaderWithParams(logger, fileDir, params...)
+			},
+		}
 	}
 
-	return nil, errors.New(fmt.Sprintf("there is no built-in uploader with name %s", name))
+	r.addGlobal()
+	return r
 }
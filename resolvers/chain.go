@@ -0,0 +1,109 @@
+package resolvers
+
+import (
+	"errors"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/transformers"
+	"github.com/takahawk/shadownet/uploaders"
+)
+
+type chainResolver struct {
+	resolvers []Resolver
+}
+
+// rootSetter is implemented by resolvers with composite components, so that
+// their parts are resolved by the whole chain
+type rootSetter interface {
+	setRoot(root Resolver)
+}
+
+// NewChainResolver returns resolver that tries given resolvers in priority
+// order (i.e. built-in, plugins, remote). The next resolver is tried only if
+// the previous one doesn't have component with a given name (returns
+// NotFoundError), other errors are returned as is
+func NewChainResolver(resolvers ...Resolver) ComponentLister {
+	cr := &chainResolver{
+		resolvers: resolvers,
+	}
+	for _, resolver := range resolvers {
+		if rs, ok := resolver.(rootSetter); ok {
+			rs.setRoot(cr)
+		}
+	}
+	return cr
+}
+
+// ResolveDownloader returns downloader from the first resolver that has it
+func (cr *chainResolver) ResolveDownloader(name string, params ...[]byte) (downloaders.Downloader, error) {
+	for _, resolver := range cr.resolvers {
+		downloader, err := resolver.ResolveDownloader(name, params...)
+		if !isNotFound(err) {
+			return downloader, err
+		}
+	}
+	return nil, &NotFoundError{Component: "downloader", Name: name}
+}
+
+// ResolveTransformer returns transformer from the first resolver that has it
+func (cr *chainResolver) ResolveTransformer(name string, params ...[]byte) (transformers.Transformer, error) {
+	for _, resolver := range cr.resolvers {
+		transformer, err := resolver.ResolveTransformer(name, params...)
+		if !isNotFound(err) {
+			return transformer, err
+		}
+	}
+	return nil, &NotFoundError{Component: "transformer", Name: name}
+}
+
+// ResolveUploader returns uploader from the first resolver that has it
+func (cr *chainResolver) ResolveUploader(name string, params ...[]byte) (uploaders.Uploader, error) {
+	for _, resolver := range cr.resolvers {
+		uploader, err := resolver.ResolveUploader(name, params...)
+		if !isNotFound(err) {
+			return uploader, err
+		}
+	}
+	return nil, &NotFoundError{Component: "uploader", Name: name}
+}
+
+// ListComponents returns components of all resolvers that are able to list
+// them. Component is listed only once, as it is resolved by the first
+// resolver having it
+func (cr *chainResolver) ListComponents() ComponentList {
+	list := newComponentList()
+	merge := func(dst *[]common.ComponentSchema, src []common.ComponentSchema) {
+		for _, schema := range src {
+			if !containsSchema(*dst, schema.Name) {
+				*dst = append(*dst, schema)
+			}
+		}
+	}
+	for _, resolver := range cr.resolvers {
+		lister, ok := resolver.(ComponentLister)
+		if !ok {
+			continue
+		}
+		components := lister.ListComponents()
+		merge(&list.Downloaders, components.Downloaders)
+		merge(&list.Transformers, components.Transformers)
+		merge(&list.Uploaders, components.Uploaders)
+	}
+	list.sort()
+	return list
+}
+
+func containsSchema(schemas []common.ComponentSchema, name string) bool {
+	for _, schema := range schemas {
+		if schema.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotFound(err error) bool {
+	var notFound *NotFoundError
+	return errors.As(err, &notFound)
+}
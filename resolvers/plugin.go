@@ -0,0 +1,463 @@
+package resolvers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/transformers"
+	"github.com/takahawk/shadownet/uploaders"
+)
+
+// PluginIntegrityErrorCode is JSON-RPC error code plugin returns if data is
+// corrupted or tampered with. It is turned into transformers.IntegrityError
+const PluginIntegrityErrorCode = 1000
+
+// DefaultPluginCallTimeout is default limit of time single call to plugin
+// can take. Plugin that doesn't respond in time is killed and started again
+// on the next call
+const DefaultPluginCallTimeout = 2 * time.Minute
+
+// DefaultMaxPluginResponseSize is default limit of size of single response
+// line of plugin in bytes
+const DefaultMaxPluginResponseSize = 128 * 1024 * 1024
+
+const jsonRPCVersion = "2.0"
+
+// PluginResolver is Resolver of components provided by plugin processes
+type PluginResolver interface {
+	ComponentLister
+	// Close stops all plugin processes
+	Close() error
+}
+
+type pluginResolver struct {
+	logger       logger.Logger
+	plugins      []*plugin
+	downloaders  map[string]pluginEntry
+	transformers map[string]pluginEntry
+	uploaders    map[string]pluginEntry
+}
+
+// pluginEntry is component provided by plugin
+type pluginEntry struct {
+	plugin *plugin
+	schema common.ComponentSchema
+}
+
+// plugin is plugin executable that is talked to with JSON-RPC over stdin and
+// stdout of its process. Process is started again if it exits or fails
+type plugin struct {
+	logger          logger.Logger
+	path            string
+	timeout         time.Duration
+	maxResponseSize int
+	mutex           sync.Mutex
+	nextID          uint64
+	// process is nil if plugin is not running
+	process *pluginProcess
+}
+
+// pluginProcess is running process of plugin
+type pluginProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// stdout is read end of pipe created for process, not by cmd, so that
+	// Wait doesn't close it before the last response is read
+	stdoutPipe *os.File
+	stdout     *bufio.Reader
+	// exited is closed once process exits and err is set to its result
+	exited chan struct{}
+	err    error
+}
+
+type callResult struct {
+	line []byte
+	err  error
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      uint64 `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type pluginDescription struct {
+	Downloaders  []pluginComponentDescription `json:"downloaders"`
+	Transformers []pluginComponentDescription `json:"transformers"`
+	Uploaders    []pluginComponentDescription `json:"uploaders"`
+}
+
+// pluginComponentDescription is either just name of component or its schema
+type pluginComponentDescription common.ComponentSchema
+
+func (pcd *pluginComponentDescription) UnmarshalJSON(data []byte) error {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		*pcd = pluginComponentDescription{Name: name, AnyParams: true}
+		return nil
+	}
+	return json.Unmarshal(data, (*common.ComponentSchema)(pcd))
+}
+
+// NewPluginResolver starts every executable file in a given directory as a
+// plugin and asks which components it provides (see plugins.md). Plugin that
+// fails to start or describe itself is skipped. Calls to plugins have default
+// time and response size limits
+func NewPluginResolver(logger logger.Logger, dir string) (PluginResolver, error) {
+	return NewPluginResolverWithLimits(logger, dir, DefaultPluginCallTimeout, DefaultMaxPluginResponseSize)
+}
+
+// NewPluginResolverWithLimits starts plugins the same way as
+// NewPluginResolver. Plugin that doesn't respond to call in timeout or
+// responds with line longer than maxResponseSize bytes is killed and started
+// again on the next call
+func NewPluginResolverWithLimits(logger logger.Logger, dir string, timeout time.Duration, maxResponseSize int) (PluginResolver, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Errorf("%+v", err)
+		return nil, err
+	}
+
+	pr := &pluginResolver{
+		logger:       logger,
+		downloaders:  make(map[string]pluginEntry),
+		transformers: make(map[string]pluginEntry),
+		uploaders:    make(map[string]pluginEntry),
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		p, description, err := startPlugin(logger, path, timeout, maxResponseSize)
+		if err != nil {
+			logger.Errorf("Error starting plugin %s: %+v", path, err)
+			continue
+		}
+		pr.plugins = append(pr.plugins, p)
+		logger.Infof("Plugin %s provides downloaders %v, transformers %v, uploaders %v", path,
+			pr.register(pr.downloaders, description.Downloaders, p),
+			pr.register(pr.transformers, description.Transformers, p),
+			pr.register(pr.uploaders, description.Uploaders, p))
+	}
+
+	return pr, nil
+}
+
+// register adds components of plugin and returns their names
+func (pr *pluginResolver) register(dict map[string]pluginEntry, descriptions []pluginComponentDescription, p *plugin) []string {
+	var names []string
+	for _, description := range descriptions {
+		name := description.Name
+		if other, ok := dict[name]; ok {
+			pr.logger.Errorf("Component %s of plugin %s is already provided by %s", name, p.path, other.plugin.path)
+			continue
+		}
+		dict[name] = pluginEntry{plugin: p, schema: common.ComponentSchema(description)}
+		names = append(names, name)
+	}
+	return names
+}
+
+// ResolveDownloader returns downloader provided by one of plugins
+func (pr *pluginResolver) ResolveDownloader(name string, params ...[]byte) (downloaders.Downloader, error) {
+	if entry, ok := pr.downloaders[name]; ok {
+		component, err := entry.component(name, params)
+		if err != nil {
+			pr.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		return &pluginDownloader{component}, nil
+	}
+
+	return nil, &NotFoundError{Resolver: "plugin", Component: "downloader", Name: name}
+}
+
+// ResolveTransformer returns transformer provided by one of plugins
+func (pr *pluginResolver) ResolveTransformer(name string, params ...[]byte) (transformers.Transformer, error) {
+	if entry, ok := pr.transformers[name]; ok {
+		component, err := entry.component(name, params)
+		if err != nil {
+			pr.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		return &pluginTransformer{component}, nil
+	}
+
+	return nil, &NotFoundError{Resolver: "plugin", Component: "transformer", Name: name}
+}
+
+// ResolveUploader returns uploader provided by one of plugins
+func (pr *pluginResolver) ResolveUploader(name string, params ...[]byte) (uploaders.Uploader, error) {
+	if entry, ok := pr.uploaders[name]; ok {
+		component, err := entry.component(name, params)
+		if err != nil {
+			pr.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		return &pluginUploader{component}, nil
+	}
+
+	return nil, &NotFoundError{Resolver: "plugin", Component: "uploader", Name: name}
+}
+
+// ListComponents returns components provided by plugins. Components described
+// by plugin only with name are listed with AnyParams schema
+func (pr *pluginResolver) ListComponents() ComponentList {
+	list := newComponentList()
+	for _, entry := range pr.downloaders {
+		list.Downloaders = append(list.Downloaders, entry.schema)
+	}
+	for _, entry := range pr.transformers {
+		list.Transformers = append(list.Transformers, entry.schema)
+	}
+	for _, entry := range pr.uploaders {
+		list.Uploaders = append(list.Uploaders, entry.schema)
+	}
+	list.sort()
+	return list
+}
+
+// component validates params and returns common part of proxy component
+func (pe pluginEntry) component(name string, params [][]byte) (pluginComponent, error) {
+	err := pe.schema.Validate(params)
+	if err != nil {
+		return pluginComponent{}, err
+	}
+	return pluginComponent{plugin: pe.plugin, name: name, params: params, schema: pe.schema}, nil
+}
+
+// Close stops all plugin processes by closing their stdin
+func (pr *pluginResolver) Close() error {
+	var result error
+	for _, p := range pr.plugins {
+		err := p.stop()
+		if err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// startPlugin starts plugin process and gets its description
+func startPlugin(logger logger.Logger, path string, timeout time.Duration, maxResponseSize int) (*plugin, *pluginDescription, error) {
+	p := &plugin{
+		logger:          logger,
+		path:            path,
+		timeout:         timeout,
+		maxResponseSize: maxResponseSize,
+	}
+	var description pluginDescription
+	err := p.call("describe", nil, &description)
+	if err != nil {
+		p.stop()
+		return nil, nil, err
+	}
+	return p, &description, nil
+}
+
+// startPluginProcess starts process of plugin executable
+func startPluginProcess(path string) (*pluginProcess, error) {
+	cmd := exec.Command(path)
+	// plugins may log to stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = stdoutWriter
+	err = cmd.Start()
+	// write end is kept only by plugin, so reading gets EOF once it exits
+	stdoutWriter.Close()
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+
+	process := &pluginProcess{
+		cmd:        cmd,
+		stdin:      stdin,
+		stdoutPipe: stdout,
+		stdout:     bufio.NewReader(stdout),
+		exited:     make(chan struct{}),
+	}
+	go func() {
+		process.err = cmd.Wait()
+		close(process.exited)
+	}()
+	return process, nil
+}
+
+// call sends JSON-RPC request and waits for response. Requests to the same
+// plugin are sent one at a time. Plugin is started if it is not running
+func (p *plugin) call(method string, params any, result any) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.process != nil && p.process.hasExited() {
+		p.logger.Errorf("Plugin %s exited: %+v", p.path, p.process.err)
+		p.process.stdoutPipe.Close()
+		p.process = nil
+	}
+	if p.process == nil {
+		process, err := startPluginProcess(p.path)
+		if err != nil {
+			return errors.New(fmt.Sprintf("plugin %s can't be started: %+v", p.path, err))
+		}
+		p.process = process
+	}
+
+	p.nextID++
+	request, err := json.Marshal(rpcRequest{
+		JSONRPC: jsonRPCVersion,
+		ID:      p.nextID,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	// plugin may hang both reading request and writing response, so they
+	// are done aside and process is killed if it takes too long
+	process := p.process
+	done := make(chan callResult, 1)
+	go func() {
+		_, err := process.stdin.Write(append(request, '\n'))
+		if err != nil {
+			done <- callResult{err: errors.New(fmt.Sprintf("plugin %s is not running: %+v", p.path, err))}
+			return
+		}
+		line, err := readLine(process.stdout, p.maxResponseSize)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("plugin %s failed to respond: %+v", p.path, err))
+		}
+		done <- callResult{line: line, err: err}
+	}()
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	var response callResult
+	select {
+	case response = <-done:
+	case <-timer.C:
+		p.kill()
+		return errors.New(fmt.Sprintf("plugin %s didn't respond in %v and is killed", p.path, p.timeout))
+	}
+	if response.err != nil {
+		p.kill()
+		return response.err
+	}
+
+	// after malformed response plugin can't be trusted to be in sync with
+	// gateway, so it is killed as well
+	var rpcResponse rpcResponse
+	err = json.Unmarshal(response.line, &rpcResponse)
+	if err != nil {
+		p.kill()
+		return errors.New(fmt.Sprintf("invalid response of plugin %s: %+v", p.path, err))
+	}
+	if rpcResponse.ID != p.nextID {
+		p.kill()
+		return errors.New(fmt.Sprintf("plugin %s responded to request %d instead of %d", p.path, rpcResponse.ID, p.nextID))
+	}
+	if rpcResponse.Error != nil {
+		return rpcResponse.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(rpcResponse.Result, result)
+}
+
+// kill kills plugin process, so that it is started again on the next call.
+// It should be called with mutex locked
+func (p *plugin) kill() {
+	if p.process == nil {
+		return
+	}
+	p.logger.Errorf("Killing plugin %s", p.path)
+	p.process.cmd.Process.Kill()
+	<-p.process.exited
+	// stdout may be still held open by children of plugin, closing it
+	// interrupts pending read
+	p.process.stdoutPipe.Close()
+	p.process = nil
+}
+
+// stop stops plugin process by closing its stdin. Process that doesn't exit
+// in timeout is killed
+func (p *plugin) stop() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.process == nil {
+		return nil
+	}
+	process := p.process
+	p.process = nil
+	process.stdin.Close()
+	select {
+	case <-process.exited:
+	case <-time.After(p.timeout):
+		process.cmd.Process.Kill()
+		<-process.exited
+	}
+	process.stdoutPipe.Close()
+	return process.err
+}
+
+func (pp *pluginProcess) hasExited() bool {
+	select {
+	case <-pp.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// readLine reads line that is at most limit bytes long
+func readLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > limit {
+			return nil, errors.New(fmt.Sprintf("line is longer than %d bytes", limit))
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
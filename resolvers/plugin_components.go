@@ -0,0 +1,132 @@
+package resolvers
+
+import (
+	"errors"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/transformers"
+)
+
+// pluginComponent is the common part of components proxied to plugins
+type pluginComponent struct {
+	plugin *plugin
+	name   string
+	params [][]byte
+	schema common.ComponentSchema
+}
+
+type pluginCallParams struct {
+	Name   string   `json:"name"`
+	Params [][]byte `json:"params"`
+	Data   []byte   `json:"data,omitempty"`
+	ID     string   `json:"id,omitempty"`
+}
+
+type pluginDataResult struct {
+	Data []byte `json:"data"`
+}
+
+type pluginUploadResult struct {
+	ID string `json:"id"`
+}
+
+type pluginDownloaderForResult struct {
+	Name   string   `json:"name"`
+	Params [][]byte `json:"params"`
+}
+
+// Name returns name of component in plugin
+func (pc *pluginComponent) Name() string {
+	return pc.name
+}
+
+// Params returns params of component
+func (pc *pluginComponent) Params() [][]byte {
+	return pc.params
+}
+
+// Schema returns schema of component given by plugin
+func (pc *pluginComponent) Schema() common.ComponentSchema {
+	return pc.schema
+}
+
+func (pc *pluginComponent) callParams() pluginCallParams {
+	return pluginCallParams{
+		Name:   pc.name,
+		Params: pc.params,
+	}
+}
+
+type pluginDownloader struct {
+	pluginComponent
+}
+
+// Download asks plugin to download data
+func (pd *pluginDownloader) Download() ([]byte, error) {
+	var result pluginDataResult
+	err := pd.plugin.call("download", pd.callParams(), &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+type pluginTransformer struct {
+	pluginComponent
+}
+
+// ForwardTransform asks plugin to transform data
+func (pt *pluginTransformer) ForwardTransform(data []byte) ([]byte, error) {
+	return pt.transform("forwardTransform", data)
+}
+
+// ReverseTransform asks plugin to get the original data back
+func (pt *pluginTransformer) ReverseTransform(data []byte) ([]byte, error) {
+	return pt.transform("reverseTransform", data)
+}
+
+func (pt *pluginTransformer) transform(method string, data []byte) ([]byte, error) {
+	params := pt.callParams()
+	params.Data = data
+	var result pluginDataResult
+	err := pt.plugin.call(method, params, &result)
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) && rpcErr.Code == PluginIntegrityErrorCode {
+		return nil, &transformers.IntegrityError{Component: pt.name, Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+type pluginUploader struct {
+	pluginComponent
+}
+
+// Upload asks plugin to upload data
+func (pu *pluginUploader) Upload(content []byte) (id string, err error) {
+	params := pu.callParams()
+	params.Data = content
+	var result pluginUploadResult
+	err = pu.plugin.call("upload", params, &result)
+	if err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+// DownloaderFor asks plugin which downloader gets data uploaded with a given
+// id. Errors are reported as downloader without name, since URL can't be
+// made anyway
+func (pu *pluginUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	callParams := pu.callParams()
+	callParams.ID = id
+	var result pluginDownloaderForResult
+	err := pu.plugin.call("downloaderFor", callParams, &result)
+	if err != nil {
+		pu.plugin.logger.Errorf("%+v", err)
+		return "", nil
+	}
+	return result.Name, result.Params
+}
@@ -0,0 +1,256 @@
+package resolvers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/transformers"
+	"github.com/takahawk/shadownet/uploaders"
+)
+
+// testPluginEnv makes test binary act as plugin instead of running tests
+const testPluginEnv = "SHADOWNET_TEST_PLUGIN"
+
+const (
+	testPluginTimeout         = time.Second
+	testPluginMaxResponseSize = 1024
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testPluginEnv) != "" {
+		runTestPlugin()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runTestPlugin serves transformers which names tell how plugin misbehaves
+// and uploader that can't tell downloader for uploaded data
+func runTestPlugin() {
+	stdin := bufio.NewScanner(os.Stdin)
+	for stdin.Scan() {
+		var request struct {
+			ID     uint64           `json:"id"`
+			Method string           `json:"method"`
+			Params pluginCallParams `json:"params"`
+		}
+		json.Unmarshal(stdin.Bytes(), &request)
+		respond := func(result any, rpcErr *rpcError) {
+			response, _ := json.Marshal(map[string]any{"jsonrpc": jsonRPCVersion, "id": request.ID, "result": result, "error": rpcErr})
+			os.Stdout.Write(append(response, '\n'))
+		}
+
+		switch request.Method {
+		case "describe":
+			respond(map[string]any{
+				"transformers": []string{"echo", "pid", "hang", "wrong-id", "huge", "garbage", "integrity", "exit"},
+				"uploaders":    []string{"no-downloader"},
+			}, nil)
+			continue
+		case "upload":
+			respond(pluginUploadResult{ID: "id"}, nil)
+			continue
+		case "downloaderFor":
+			respond(nil, &rpcError{Code: -32000, Message: "unknown id"})
+			continue
+		}
+
+		data := request.Params.Data
+		switch request.Params.Name {
+		case "echo":
+			respond(pluginDataResult{Data: data}, nil)
+		case "pid":
+			respond(pluginDataResult{Data: []byte(strconv.Itoa(os.Getpid()))}, nil)
+		case "hang":
+			time.Sleep(time.Hour)
+		case "wrong-id":
+			request.ID += 100
+			respond(pluginDataResult{Data: data}, nil)
+		case "huge":
+			respond(pluginDataResult{Data: bytes.Repeat([]byte{'x'}, testPluginMaxResponseSize)}, nil)
+		case "garbage":
+			fmt.Println("not json")
+		case "integrity":
+			respond(nil, &rpcError{Code: PluginIntegrityErrorCode, Message: "signature mismatch"})
+		case "exit":
+			// answers and exits at once, response should not be lost
+			respond(pluginDataResult{Data: data}, nil)
+			os.Exit(0)
+		}
+	}
+}
+
+// newTestPluginResolver makes plugin directory with script that runs test
+// binary as plugin
+func newTestPluginResolver(t *testing.T) PluginResolver {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\n%s=1 exec %q\n", testPluginEnv, executable)
+	err = os.WriteFile(filepath.Join(dir, "test-plugin"), []byte(script), 0755)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	resolver, err := NewPluginResolverWithLimits(log, dir, testPluginTimeout, testPluginMaxResponseSize)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(func() { resolver.Close() })
+	return resolver
+}
+
+func resolveTestTransformer(t *testing.T, resolver PluginResolver, name string) transformers.Transformer {
+	transformer, err := resolver.ResolveTransformer(name)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return transformer
+}
+
+func TestPluginDescribe(t *testing.T) {
+	resolver := newTestPluginResolver(t)
+	list := resolver.ListComponents()
+	if len(list.Transformers) != 8 || len(list.Uploaders) != 1 {
+		t.Fatalf("unexpected components: %+v", list)
+	}
+}
+
+func TestPluginRoundTrip(t *testing.T) {
+	resolver := newTestPluginResolver(t)
+	echo := resolveTestTransformer(t, resolver, "echo")
+	for _, data := range [][]byte{[]byte("data"), {0, 1, 2, 255}} {
+		forward, err := echo.ForwardTransform(data)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		reverse, err := echo.ReverseTransform(forward)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !bytes.Equal(reverse, data) {
+			t.Fatalf("expected %q, got %q", data, reverse)
+		}
+	}
+}
+
+// TestPluginRestart checks that plugin misbehaving in a given way is killed
+// and the next call goes to the new process
+func TestPluginRestart(t *testing.T) {
+	tests := []struct {
+		name  string
+		error string
+	}{
+		{"hang", "didn't respond"},
+		{"wrong-id", "instead of"},
+		{"huge", "longer than"},
+		{"garbage", "invalid response"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver := newTestPluginResolver(t)
+			pid := resolveTestTransformer(t, resolver, "pid")
+			before, err := pid.ForwardTransform(nil)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			_, err = resolveTestTransformer(t, resolver, test.name).ForwardTransform([]byte("data"))
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Fatalf("expected error with %q, got %v", test.error, err)
+			}
+
+			after, err := pid.ForwardTransform(nil)
+			if err != nil {
+				t.Fatalf("plugin is not restarted: %+v", err)
+			}
+			if bytes.Equal(before, after) {
+				t.Fatalf("plugin process %s is not killed", before)
+			}
+		})
+	}
+}
+
+func TestPluginExitAfterResponse(t *testing.T) {
+	resolver := newTestPluginResolver(t)
+	exit := resolveTestTransformer(t, resolver, "exit")
+	for i := 0; i < 3; i++ {
+		data, err := exit.ForwardTransform([]byte("data"))
+		if err != nil {
+			t.Fatalf("response %d of exiting plugin is lost: %+v", i, err)
+		}
+		if string(data) != "data" {
+			t.Fatalf("unexpected response: %q", data)
+		}
+		// call made while plugin is exiting fails, the one after exit
+		// restarts it
+		waitTestPluginExit(t, resolver)
+	}
+}
+
+func waitTestPluginExit(t *testing.T, resolver PluginResolver) {
+	p := resolver.(*pluginResolver).plugins[0]
+	p.mutex.Lock()
+	process := p.process
+	p.mutex.Unlock()
+	select {
+	case <-process.exited:
+	case <-time.After(10 * time.Second):
+		t.Fatal("plugin hasn't exited")
+	}
+}
+
+func TestPluginIntegrityError(t *testing.T) {
+	resolver := newTestPluginResolver(t)
+	_, err := resolveTestTransformer(t, resolver, "integrity").ReverseTransform([]byte("data"))
+	var integrityErr *transformers.IntegrityError
+	if !errors.As(err, &integrityErr) || integrityErr.Component != "integrity" {
+		t.Fatalf("expected integrity error, got %v", err)
+	}
+
+	// plugin reporting error is still in sync and is not restarted
+	_, err = resolveTestTransformer(t, resolver, "echo").ForwardTransform([]byte("data"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+}
+
+func TestPluginUploaderWithoutDownloader(t *testing.T) {
+	resolver := newTestPluginResolver(t)
+	uploader, err := resolver.ResolveUploader("no-downloader")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	id, err := uploader.Upload([]byte("data"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	name, _ := uploader.DownloaderFor(id)
+	if name != "" {
+		t.Fatalf("expected no downloader, got %s", name)
+	}
+
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	mirror, err := uploaders.NewMirrorUploader(log, 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	mirror.AddUploaders(uploader)
+	_, err = mirror.Upload([]byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "hasn't provided downloader") {
+		t.Fatalf("upload without downloader should fail, got %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package resolvers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/transformers"
+	"github.com/takahawk/shadownet/uploaders"
+)
+
+// DownloaderFactory returns new downloader for a given params
+type DownloaderFactory func(logger logger.Logger, params ...[]byte) (downloaders.Downloader, error)
+
+// TransformerFactory returns new transformer for a given params
+type TransformerFactory func(logger logger.Logger, params ...[]byte) (transformers.Transformer, error)
+
+// UploaderFactory returns new uploader for a given params
+type UploaderFactory func(logger logger.Logger, params ...[]byte) (uploaders.Uploader, error)
+
+// Registry is Resolver which components can be registered at runtime. Params
+// are validated against schema of component before factory is called
+type Registry interface {
+	ComponentLister
+	// RegisterDownloader adds downloader factory with a given schema
+	RegisterDownloader(schema common.ComponentSchema, factory DownloaderFactory) error
+	// RegisterTransformer adds transformer factory with a given schema
+	RegisterTransformer(schema common.ComponentSchema, factory TransformerFactory) error
+	// RegisterUploader adds uploader factory with a given schema
+	RegisterUploader(schema common.ComponentSchema, factory UploaderFactory) error
+}
+
+type downloaderEntry struct {
+	schema  common.ComponentSchema
+	factory DownloaderFactory
+}
+
+type transformerEntry struct {
+	schema  common.ComponentSchema
+	factory TransformerFactory
+}
+
+type uploaderEntry struct {
+	schema  common.ComponentSchema
+	factory UploaderFactory
+}
+
+// NotFoundError is returned by resolvers if there is no component with a
+// given name, so that chain resolver can try the next one
+type NotFoundError struct {
+	// Resolver is kind of resolver (i.e. "built-in", "plugin")
+	Resolver string
+	// Component is type of component: "downloader", "transformer" or
+	// "uploader"
+	Component string
+	// Name is name of component
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Resolver == "" {
+		return fmt.Sprintf("there is no %s with name %s", e.Component, e.Name)
+	}
+	return fmt.Sprintf("there is no %s %s with name %s", e.Resolver, e.Component, e.Name)
+}
+
+type registry struct {
+	logger       logger.Logger
+	kind         string
+	mutex        sync.RWMutex
+	downloaders  map[string]downloaderEntry
+	transformers map[string]transformerEntry
+	uploaders    map[string]uploaderEntry
+	// root is resolver that parts of composite components are resolved with
+	root Resolver
+}
+
+var (
+	globalMutex        sync.Mutex
+	globalDownloaders  []downloaderEntry
+	globalTransformers []transformerEntry
+	globalUploaders    []uploaderEntry
+)
+
+// RegisterDownloader adds downloader factory to every registry created after
+// it. It is meant to be called from init functions of packages providing
+// components and panics if name is already taken
+func RegisterDownloader(schema common.ComponentSchema, factory DownloaderFactory) {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	for _, entry := range globalDownloaders {
+		if entry.schema.Name == schema.Name {
+			panic(fmt.Sprintf("downloader %s is already registered", schema.Name))
+		}
+	}
+	globalDownloaders = append(globalDownloaders, downloaderEntry{schema: schema, factory: factory})
+}
+
+// RegisterTransformer adds transformer factory to every registry created
+// after it. It is meant to be called from init functions of packages
+// providing components and panics if name is already taken
+func RegisterTransformer(schema common.ComponentSchema, factory TransformerFactory) {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	for _, entry := range globalTransformers {
+		if entry.schema.Name == schema.Name {
+			panic(fmt.Sprintf("transformer %s is already registered", schema.Name))
+		}
+	}
+	globalTransformers = append(globalTransformers, transformerEntry{schema: schema, factory: factory})
+}
+
+// RegisterUploader adds uploader factory to every registry created after it.
+// It is meant to be called from init functions of packages providing
+// components and panics if name is already taken
+func RegisterUploader(schema common.ComponentSchema, factory UploaderFactory) {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	for _, entry := range globalUploaders {
+		if entry.schema.Name == schema.Name {
+			panic(fmt.Sprintf("uploader %s is already registered", schema.Name))
+		}
+	}
+	globalUploaders = append(globalUploaders, uploaderEntry{schema: schema, factory: factory})
+}
+
+// NewRegistry returns registry containing only components registered
+// globally with Register* functions
+func NewRegistry(logger logger.Logger) Registry {
+	r := newRegistry(logger, "registered")
+	r.addGlobal()
+	return r
+}
+
+func newRegistry(logger logger.Logger, kind string) *registry {
+	return &registry{
+		logger:       logger,
+		kind:         kind,
+		downloaders:  make(map[string]downloaderEntry),
+		transformers: make(map[string]transformerEntry),
+		uploaders:    make(map[string]uploaderEntry),
+	}
+}
+
+// addGlobal adds globally registered components to registry. Components
+// with names that are already taken are skipped
+func (r *registry) addGlobal() {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	for _, entry := range globalDownloaders {
+		if err := r.RegisterDownloader(entry.schema, entry.factory); err != nil {
+			r.logger.Errorf("%+v", err)
+		}
+	}
+	for _, entry := range globalTransformers {
+		if err := r.RegisterTransformer(entry.schema, entry.factory); err != nil {
+			r.logger.Errorf("%+v", err)
+		}
+	}
+	for _, entry := range globalUploaders {
+		if err := r.RegisterUploader(entry.schema, entry.factory); err != nil {
+			r.logger.Errorf("%+v", err)
+		}
+	}
+}
+
+// RegisterDownloader adds downloader factory with a given name. Error is
+// returned if name is already taken
+func (r *registry) RegisterDownloader(schema common.ComponentSchema, factory DownloaderFactory) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.downloaders[schema.Name]; ok {
+		return errors.New(fmt.Sprintf("downloader %s is already registered", schema.Name))
+	}
+	r.downloaders[schema.Name] = downloaderEntry{schema: schema, factory: factory}
+	return nil
+}
+
+// RegisterTransformer adds transformer factory with a given name. Error is
+// returned if name is already taken
+func (r *registry) RegisterTransformer(schema common.ComponentSchema, factory TransformerFactory) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.transformers[schema.Name]; ok {
+		return errors.New(fmt.Sprintf("transformer %s is already registered", schema.Name))
+	}
+	r.transformers[schema.Name] = transformerEntry{schema: schema, factory: factory}
+	return nil
+}
+
+// RegisterUploader adds uploader factory with a given name. Error is returned
+// if name is already taken
+func (r *registry) RegisterUploader(schema common.ComponentSchema, factory UploaderFactory) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.uploaders[schema.Name]; ok {
+		return errors.New(fmt.Sprintf("uploader %s is already registered", schema.Name))
+	}
+	r.uploaders[schema.Name] = uploaderEntry{schema: schema, factory: factory}
+	return nil
+}
+
+// ResolveDownloader returns one of registered downloaders
+func (r *registry) ResolveDownloader(name string, params ...[]byte) (downloaders.Downloader, error) {
+	r.mutex.RLock()
+	entry, ok := r.downloaders[name]
+	r.mutex.RUnlock()
+	if ok {
+		err := entry.schema.Validate(params)
+		if err != nil {
+			r.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		return entry.factory(r.logger, params...)
+	}
+
+	return nil, &NotFoundError{Resolver: r.kind, Component: "downloader", Name: name}
+}
+
+// ResolveTransformer returns one of registered transformers
+func (r *registry) ResolveTransformer(name string, params ...[]byte) (transformers.Transformer, error) {
+	r.mutex.RLock()
+	entry, ok := r.transformers[name]
+	r.mutex.RUnlock()
+	if ok {
+		err := entry.schema.Validate(params)
+		if err != nil {
+			r.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		return entry.factory(r.logger, params...)
+	}
+
+	return nil, &NotFoundError{Resolver: r.kind, Component: "transformer", Name: name}
+}
+
+// ResolveUploader returns one of registered uploaders
+func (r *registry) ResolveUploader(name string, params ...[]byte) (uploaders.Uploader, error) {
+	r.mutex.RLock()
+	entry, ok := r.uploaders[name]
+	r.mutex.RUnlock()
+	if ok {
+		err := entry.schema.Validate(params)
+		if err != nil {
+			r.logger.Errorf("%+v", err)
+			return nil, err
+		}
+		return entry.factory(r.logger, params...)
+	}
+
+	return nil, &NotFoundError{Resolver: r.kind, Component: "uploader", Name: name}
+}
+
+// ListComponents returns schemas of registered components sorted by name
+func (r *registry) ListComponents() ComponentList {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	list := newComponentList()
+	for _, entry := range r.downloaders {
+		list.Downloaders = append(list.Downloaders, entry.schema)
+	}
+	for _, entry := range r.transformers {
+		list.Transformers = append(list.Transformers, entry.schema)
+	}
+	for _, entry := range r.uploaders {
+		list.Uploaders = append(list.Uploaders, entry.schema)
+	}
+	list.sort()
+	return list
+}
+
+// setRoot makes parts of composite components to be resolved with a given
+// resolver
+func (r *registry) setRoot(root Resolver) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.root = root
+}
+
+func (r *registry) rootResolver() Resolver {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.root == nil {
+		return r
+	}
+	return r.root
+}
@@ -1,6 +1,9 @@
 package resolvers
 
 import (
+	"sort"
+
+	"github.com/takahawk/shadownet/common"
 	"github.com/takahawk/shadownet/downloaders"
 	"github.com/takahawk/shadownet/transformers"
 	"github.com/takahawk/shadownet/uploaders"
@@ -9,7 +12,8 @@ import (
 // Resolver can be used to get ShadowNet component given a name, parameters and
 // type of component
 type Resolver interface {
-	// ResolveDownloader returns Downloader by name and parameters
+	// ResolveDownloader returns Downloader by name and parameters. If there is
+	// no component with such name, *NotFoundError is returned
 	ResolveDownloader(name string, params ...[]byte) (downloaders.Downloader, error)
 	// ResolveDownloader returns Transformer by name and parameters
 	ResolveTransformer(name string, params ...[]byte) (transformers.Transformer, error)
@@ -17,4 +21,36 @@ type Resolver interface {
 	ResolveUploader(name string, params ...[]byte) (uploaders.Uploader, error)
 }
 
-// TODO: add plugin and/or socket and/or IPC bridge implementations
+// ComponentList is list of schemas of components resolver provides
+type ComponentList struct {
+	Downloaders  []common.ComponentSchema `json:"downloaders"`
+	Transformers []common.ComponentSchema `json:"transformers"`
+	Uploaders    []common.ComponentSchema `json:"uploaders"`
+}
+
+// ComponentLister is Resolver that is able to list components it provides
+type ComponentLister interface {
+	Resolver
+	// ListComponents returns schemas of all components resolver provides
+	ListComponents() ComponentList
+}
+
+// newComponentList returns empty list, which lists are marshaled as empty
+// arrays rather than nulls
+func newComponentList() ComponentList {
+	return ComponentList{
+		Downloaders:  []common.ComponentSchema{},
+		Transformers: []common.ComponentSchema{},
+		Uploaders:    []common.ComponentSchema{},
+	}
+}
+
+func (cl *ComponentList) sort() {
+	for _, schemas := range [][]common.ComponentSchema{cl.Downloaders, cl.Transformers, cl.Uploaders} {
+		sort.Slice(schemas, func(i, j int) bool {
+			return schemas[i].Name < schemas[j].Name
+		})
+	}
+}
+
+// TODO: add socket and/or remote bridge implementations
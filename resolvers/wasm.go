@@ -0,0 +1,354 @@
+package resolvers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/transformers"
+	"github.com/takahawk/shadownet/uploaders"
+)
+
+// WasmTransformerName is wasm transformer component name
+const WasmTransformerName = "wasm"
+
+// WasmTransformerSchema describes wasm transformer and its params
+var WasmTransformerSchema = common.ComponentSchema{
+	Name:        WasmTransformerName,
+	Description: "Runs transformer compiled to WebAssembly in sandbox",
+	Params: []common.ParamSchema{
+		{Name: "hash", Type: common.ParamTypeString, Description: "SHA-256 hash (hex) of module", Lengths: []int{sha256.Size * 2}},
+	},
+}
+
+// DefaultWasmMemoryLimitPages is default limit of wasm module memory in 64 KiB
+// pages (64 MiB)
+const DefaultWasmMemoryLimitPages = 1024
+
+// DefaultWasmTimeout is default limit of time single transform can take
+const DefaultWasmTimeout = 10 * time.Second
+
+// Codes wasm module returns from forward and reverse functions on failure
+// (see wasm.md)
+const (
+	WasmErrorCode          = -1
+	WasmIntegrityErrorCode = -2
+)
+
+// wasmHostModuleName is name of module with functions wasm modules can import
+const wasmHostModuleName = "shadownet"
+
+// WasmResolver is Resolver of transformers compiled to WebAssembly. It
+// resolves only "wasm" transformer which single param is SHA-256 hash (hex) of
+// module content
+type WasmResolver interface {
+	ComponentLister
+	// Close releases all compiled modules
+	Close() error
+}
+
+type wasmResolver struct {
+	logger  logger.Logger
+	runtime wazero.Runtime
+	timeout time.Duration
+	// modules are compiled modules by hash of their content
+	modules map[string]wazero.CompiledModule
+}
+
+type wasmTransformer struct {
+	resolver *wasmResolver
+	hash     string
+	module   wazero.CompiledModule
+}
+
+// wasmErrorKey is context key of string wasm module error message is written
+// to
+type wasmErrorKey struct{}
+
+// NewWasmResolver compiles every .wasm file in a given directory with default
+// memory and time limits. Module that fails to compile or doesn't follow ABI
+// is skipped
+func NewWasmResolver(logger logger.Logger, dir string) (WasmResolver, error) {
+	return NewWasmResolverWithLimits(logger, dir, DefaultWasmMemoryLimitPages, DefaultWasmTimeout)
+}
+
+// NewWasmResolverWithLimits compiles every .wasm file in a given directory.
+// Memory of modules is limited to memoryLimitPages 64 KiB pages and every
+// transform is stopped after timeout
+func NewWasmResolverWithLimits(logger logger.Logger, dir string, memoryLimitPages uint32, timeout time.Duration) (WasmResolver, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Errorf("%+v", err)
+		return nil, err
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(memoryLimitPages).
+		WithCloseOnContextDone(true))
+	// WASI is provided so that modules built by common toolchains can be
+	// loaded. Modules get neither filesystem nor environment access
+	_, err = wasi_snapshot_preview1.Instantiate(ctx, runtime)
+	if err == nil {
+		_, err = runtime.NewHostModuleBuilder(wasmHostModuleName).
+			NewFunctionBuilder().WithFunc(wasmError).Export("error").
+			Instantiate(ctx)
+	}
+	if err != nil {
+		logger.Errorf("%+v", err)
+		runtime.Close(ctx)
+		return nil, err
+	}
+
+	wr := &wasmResolver{
+		logger:  logger,
+		runtime: runtime,
+		timeout: timeout,
+		modules: make(map[string]wazero.CompiledModule),
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".wasm") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		hash, err := wr.load(ctx, path)
+		if err != nil {
+			logger.Errorf("Error loading wasm module %s: %+v", path, err)
+			continue
+		}
+		logger.Infof("Wasm module %s is loaded with hash %s", path, hash)
+	}
+
+	return wr, nil
+}
+
+// load compiles module and checks that it exports everything needed
+func (wr *wasmResolver) load(ctx context.Context, path string) (hash string, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	hash = hex.EncodeToString(sum[:])
+	if _, ok := wr.modules[hash]; ok {
+		return "", errors.New(fmt.Sprintf("module with hash %s is already loaded", hash))
+	}
+
+	module, err := wr.runtime.CompileModule(ctx, content)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := module.ExportedMemories()["memory"]; !ok {
+		module.Close(ctx)
+		return "", errors.New("module should export memory")
+	}
+	functions := module.ExportedFunctions()
+	signatures := []struct {
+		name    string
+		params  []api.ValueType
+		results []api.ValueType
+	}{
+		{"alloc", []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}},
+		{"forward", []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}},
+		{"reverse", []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}},
+	}
+	for _, signature := range signatures {
+		function, ok := functions[signature.name]
+		if !ok || !sameTypes(function.ParamTypes(), signature.params) || !sameTypes(function.ResultTypes(), signature.results) {
+			module.Close(ctx)
+			return "", errors.New(fmt.Sprintf("module should export function %s with ABI signature", signature.name))
+		}
+	}
+
+	wr.modules[hash] = module
+	return hash, nil
+}
+
+func sameTypes(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// wasmError is host function module calls to describe error before
+// returning error code
+func wasmError(ctx context.Context, m api.Module, ptr, length uint32) {
+	message, ok := ctx.Value(wasmErrorKey{}).(*string)
+	if !ok {
+		return
+	}
+	if data, ok := m.Memory().Read(ptr, length); ok {
+		*message = string(data)
+	}
+}
+
+// ResolveDownloader always fails, since wasm modules provide only transformers
+func (wr *wasmResolver) ResolveDownloader(name string, params ...[]byte) (downloaders.Downloader, error) {
+	return nil, &NotFoundError{Resolver: "wasm", Component: "downloader", Name: name}
+}
+
+// ResolveTransformer returns transformer running wasm module with a given
+// hash
+func (wr *wasmResolver) ResolveTransformer(name string, params ...[]byte) (transformers.Transformer, error) {
+	if name != WasmTransformerName {
+		return nil, &NotFoundError{Resolver: "wasm", Component: "transformer", Name: name}
+	}
+	err := WasmTransformerSchema.Validate(params)
+	if err != nil {
+		wr.logger.Errorf("%+v", err)
+		return nil, err
+	}
+
+	hash := string(params[0])
+	module, ok := wr.modules[hash]
+	if !ok {
+		err := errors.New(fmt.Sprintf("there is no wasm module with hash %s", hash))
+		wr.logger.Errorf("%+v", err)
+		return nil, err
+	}
+	return &wasmTransformer{
+		resolver: wr,
+		hash:     hash,
+		module:   module,
+	}, nil
+}
+
+// ResolveUploader always fails, since wasm modules provide only transformers
+func (wr *wasmResolver) ResolveUploader(name string, params ...[]byte) (uploaders.Uploader, error) {
+	return nil, &NotFoundError{Resolver: "wasm", Component: "uploader", Name: name}
+}
+
+// ListComponents returns wasm transformer if there is at least one module
+func (wr *wasmResolver) ListComponents() ComponentList {
+	list := newComponentList()
+	if len(wr.modules) != 0 {
+		list.Transformers = append(list.Transformers, WasmTransformerSchema)
+	}
+	return list
+}
+
+// Close releases all compiled modules
+func (wr *wasmResolver) Close() error {
+	return wr.runtime.Close(context.Background())
+}
+
+// Name returns wasm transformer name. It is always WasmTransformerName
+func (wt *wasmTransformer) Name() string {
+	return WasmTransformerName
+}
+
+// Schema returns schema of wasm transformer. It is always
+// WasmTransformerSchema
+func (wt *wasmTransformer) Schema() common.ComponentSchema {
+	return WasmTransformerSchema
+}
+
+// Params returns hash of module
+func (wt *wasmTransformer) Params() [][]byte {
+	return [][]byte{[]byte(wt.hash)}
+}
+
+// ForwardTransform runs forward function of module
+func (wt *wasmTransformer) ForwardTransform(data []byte) ([]byte, error) {
+	return wt.transform("forward", data)
+}
+
+// ReverseTransform runs reverse function of module
+func (wt *wasmTransformer) ReverseTransform(data []byte) ([]byte, error) {
+	return wt.transform("reverse", data)
+}
+
+// transform runs function in a new module instance, so nothing is shared
+// between calls
+func (wt *wasmTransformer) transform(function string, data []byte) ([]byte, error) {
+	logger := wt.resolver.logger
+	if uint64(len(data)) > math.MaxUint32 {
+		return nil, errors.New("data is too big for wasm module")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wt.resolver.timeout)
+	defer cancel()
+	var message string
+	ctx = context.WithValue(ctx, wasmErrorKey{}, &message)
+
+	module, err := wt.resolver.runtime.InstantiateModule(ctx, wt.module, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		err = wt.wrapError(ctx, err)
+		logger.Errorf("%+v", err)
+		return nil, err
+	}
+	defer module.Close(ctx)
+
+	results, err := module.ExportedFunction("alloc").Call(ctx, uint64(len(data)))
+	if err != nil {
+		err = wt.wrapError(ctx, err)
+		logger.Errorf("%+v", err)
+		return nil, err
+	}
+	ptr := uint32(results[0])
+	if !module.Memory().Write(ptr, data) {
+		err = errors.New(fmt.Sprintf("wasm module %s allocated memory out of range", wt.hash))
+		logger.Errorf("%+v", err)
+		return nil, err
+	}
+
+	results, err = module.ExportedFunction(function).Call(ctx, uint64(ptr), uint64(len(data)))
+	if err != nil {
+		err = wt.wrapError(ctx, err)
+		logger.Errorf("%+v", err)
+		return nil, err
+	}
+	result := int64(results[0])
+	if result < 0 {
+		description := fmt.Sprintf("wasm module %s failed with code %d", wt.hash, result)
+		if message != "" {
+			description += ": " + message
+		}
+		err = errors.New(description)
+		if result == WasmIntegrityErrorCode {
+			return nil, &transformers.IntegrityError{Component: WasmTransformerName, Err: err}
+		}
+		logger.Errorf("%+v", err)
+		return nil, err
+	}
+
+	// result is pointer to output in the high 32 bits and its length in the
+	// low ones
+	output, ok := module.Memory().Read(uint32(result>>32), uint32(result))
+	if !ok {
+		err = errors.New(fmt.Sprintf("wasm module %s returned output out of range", wt.hash))
+		logger.Errorf("%+v", err)
+		return nil, err
+	}
+	// memory is released along with module instance
+	return append([]byte(nil), output...), nil
+}
+
+func (wt *wasmTransformer) wrapError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return errors.New(fmt.Sprintf("wasm module %s exceeded time limit", wt.hash))
+	}
+	return errors.New(fmt.Sprintf("wasm module %s failed: %+v", wt.hash, err))
+}
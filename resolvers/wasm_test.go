@@ -0,0 +1,373 @@
+package resolvers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/transformers"
+)
+
+const (
+	testWasmMemoryLimitPages = 4
+	testWasmTimeout          = 500 * time.Millisecond
+	// testWasmInput is where alloc of test modules places input
+	testWasmInput = 1024
+)
+
+// wasm opcodes used by test modules
+const (
+	opBlock      = 0x02
+	opLoop       = 0x03
+	opIf         = 0x04
+	opEnd        = 0x0b
+	opBr         = 0x0c
+	opBrIf       = 0x0d
+	opReturn     = 0x0f
+	opCall       = 0x10
+	opLocalGet   = 0x20
+	opLocalSet   = 0x21
+	opI32Load8U  = 0x2d
+	opI32Store8  = 0x3a
+	opMemoryGrow = 0x40
+	opI32Const   = 0x41
+	opI64Const   = 0x42
+	opI32Eq      = 0x46
+	opI32GeU     = 0x4f
+	opI32Add     = 0x6a
+	opI64Or      = 0x84
+	opI64Shl     = 0x86
+	opI64ExtendU = 0xad
+	blockEmpty   = 0x40
+)
+
+// testWasmModule is module following ABI of wasm transformers. Function
+// bodies are instructions without locals and final end
+type testWasmModule struct {
+	memoryPages int
+	alloc       []byte
+	forward     []byte
+	reverse     []byte
+	// data is put at address 0
+	data []byte
+}
+
+// assemble encodes module that imports shadownet.error (function 0) and
+// exports memory, alloc (1), forward (2) and reverse (3). Forward and reverse
+// have one i32 local besides params
+func (twm testWasmModule) assemble() []byte {
+	i32, i64 := byte(0x7f), byte(0x7e)
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = append(module, wasmSection(1, wasmVector(
+		[]byte{0x60, 1, i32, 1, i32},
+		[]byte{0x60, 2, i32, i32, 1, i64},
+		[]byte{0x60, 2, i32, i32, 0},
+	))...)
+	module = append(module, wasmSection(2, wasmVector(
+		concat(wasmName(wasmHostModuleName), wasmName("error"), []byte{0x00, 2}),
+	))...)
+	module = append(module, wasmSection(3, wasmVector([]byte{0}, []byte{1}, []byte{1}))...)
+	module = append(module, wasmSection(5, wasmVector(concat([]byte{0x00}, uleb(uint64(twm.memoryPages)))))...)
+	module = append(module, wasmSection(7, wasmVector(
+		concat(wasmName("memory"), []byte{0x02, 0}),
+		concat(wasmName("alloc"), []byte{0x00, 1}),
+		concat(wasmName("forward"), []byte{0x00, 2}),
+		concat(wasmName("reverse"), []byte{0x00, 3}),
+	))...)
+	module = append(module, wasmSection(10, wasmVector(
+		wasmFunction(nil, twm.alloc),
+		wasmFunction([]byte{1, 1, i32}, twm.forward),
+		wasmFunction([]byte{1, 1, i32}, twm.reverse),
+	))...)
+	if twm.data != nil {
+		module = append(module, wasmSection(11, wasmVector(
+			concat([]byte{0x00, opI32Const, 0, opEnd}, uleb(uint64(len(twm.data))), twm.data),
+		))...)
+	}
+	return module
+}
+
+func wasmSection(id byte, content []byte) []byte {
+	return concat([]byte{id}, uleb(uint64(len(content))), content)
+}
+
+func wasmVector(items ...[]byte) []byte {
+	return concat(append([][]byte{uleb(uint64(len(items)))}, items...)...)
+}
+
+func wasmName(name string) []byte {
+	return concat(uleb(uint64(len(name))), []byte(name))
+}
+
+func wasmFunction(locals []byte, body []byte) []byte {
+	if locals == nil {
+		locals = []byte{0}
+	}
+	function := concat(locals, body, []byte{opEnd})
+	return concat(uleb(uint64(len(function))), function)
+}
+
+func concat(parts ...[]byte) []byte {
+	var result []byte
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result
+}
+
+func uleb(value uint64) []byte {
+	var result []byte
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if value == 0 {
+			return append(result, b)
+		}
+		result = append(result, b|0x80)
+	}
+}
+
+func sleb(value int64) []byte {
+	var result []byte
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if (value == 0 && b&0x40 == 0) || (value == -1 && b&0x40 != 0) {
+			return append(result, b)
+		}
+		result = append(result, b|0x80)
+	}
+}
+
+func i32Const(value int32) []byte {
+	return concat([]byte{opI32Const}, sleb(int64(value)))
+}
+
+func i64Const(value int64) []byte {
+	return concat([]byte{opI64Const}, sleb(value))
+}
+
+// allocInput returns testWasmInput:
+//
+//	i32.const 1024
+func allocInput() []byte {
+	return i32Const(testWasmInput)
+}
+
+// shiftInput adds delta to every input byte in place and returns input:
+//
+//	block
+//	  loop
+//	    (br_if 1 (i32.ge_u (local.get 2) (local.get 1)))
+//	    (i32.store8 (i32.add (local.get 0) (local.get 2))
+//	      (i32.add (i32.load8_u (i32.add (local.get 0) (local.get 2))) (i32.const delta)))
+//	    (local.set 2 (i32.add (local.get 2) (i32.const 1)))
+//	    br 0
+//	  end
+//	end
+//	(i64.or (i64.shl (i64.extend_i32_u (local.get 0)) (i64.const 32))
+//	  (i64.extend_i32_u (local.get 1)))
+func shiftInput(delta int32) []byte {
+	return concat(
+		[]byte{opBlock, blockEmpty, opLoop, blockEmpty},
+		[]byte{opLocalGet, 2, opLocalGet, 1, opI32GeU, opBrIf, 1},
+		[]byte{opLocalGet, 0, opLocalGet, 2, opI32Add},
+		[]byte{opLocalGet, 0, opLocalGet, 2, opI32Add, opI32Load8U, 0, 0},
+		i32Const(delta), []byte{opI32Add, opI32Store8, 0, 0},
+		[]byte{opLocalGet, 2}, i32Const(1), []byte{opI32Add, opLocalSet, 2},
+		[]byte{opBr, 0, opEnd, opEnd},
+		returnInput(),
+	)
+}
+
+func returnInput() []byte {
+	return concat(
+		[]byte{opLocalGet, 0, opI64ExtendU}, i64Const(32), []byte{opI64Shl},
+		[]byte{opLocalGet, 1, opI64ExtendU, opI64Or},
+	)
+}
+
+// hang loops forever:
+//
+//	loop br 0 end
+//	i64.const 0
+func hang() []byte {
+	return concat([]byte{opLoop, blockEmpty, opBr, 0, opEnd}, i64Const(0))
+}
+
+// growByInputLength grows memory by as many pages as input is long and fails
+// if it can't:
+//
+//	(if (i32.eq (memory.grow (local.get 1)) (i32.const -1)) (then (return (i64.const -1))))
+//	i64.const 0
+func growByInputLength() []byte {
+	return concat(
+		[]byte{opLocalGet, 1, opMemoryGrow, 0}, i32Const(-1), []byte{opI32Eq},
+		[]byte{opIf, blockEmpty}, i64Const(WasmErrorCode), []byte{opReturn, opEnd},
+		i64Const(0),
+	)
+}
+
+// failWithMessage describes error with message at address 0 and returns
+// code:
+//
+//	(call 0 (i32.const 0) (i32.const len))
+//	i64.const code
+func failWithMessage(length int, code int64) []byte {
+	return concat(i32Const(0), i32Const(int32(length)), []byte{opCall, 0}, i64Const(code))
+}
+
+func newTestWasmResolver(t *testing.T, modules map[string]testWasmModule) (WasmResolver, map[string]string) {
+	dir := t.TempDir()
+	hashes := make(map[string]string)
+	for name, module := range modules {
+		content := module.assemble()
+		err := os.WriteFile(filepath.Join(dir, name+".wasm"), content, 0644)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		sum := sha256.Sum256(content)
+		hashes[name] = hex.EncodeToString(sum[:])
+	}
+
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	resolver, err := NewWasmResolverWithLimits(log, dir, testWasmMemoryLimitPages, testWasmTimeout)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(func() { resolver.Close() })
+	return resolver, hashes
+}
+
+func TestWasmRoundTrip(t *testing.T) {
+	resolver, hashes := newTestWasmResolver(t, map[string]testWasmModule{
+		"shift": {memoryPages: 1, alloc: allocInput(), forward: shiftInput(1), reverse: shiftInput(-1)},
+	})
+	if len(resolver.ListComponents().Transformers) != 1 {
+		t.Fatal("wasm transformer should be listed")
+	}
+	transformer, err := resolver.ResolveTransformer(WasmTransformerName, []byte(hashes["shift"]))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	for _, data := range []string{"abc", "", string([]byte{0, 255})} {
+		forward, err := transformer.ForwardTransform([]byte(data))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(data) != 0 && forward[0] != data[0]+1 {
+			t.Fatalf("unexpected output of forward: %v", forward)
+		}
+		reverse, err := transformer.ReverseTransform(forward)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if string(reverse) != data {
+			t.Fatalf("expected %q, got %q", data, reverse)
+		}
+	}
+}
+
+func TestWasmModuleNotLoaded(t *testing.T) {
+	resolver, hashes := newTestWasmResolver(t, map[string]testWasmModule{
+		// the minimum memory of module is already above limit
+		"big": {memoryPages: testWasmMemoryLimitPages + 1, alloc: allocInput(), forward: returnInput(), reverse: returnInput()},
+	})
+	_, err := resolver.ResolveTransformer(WasmTransformerName, []byte(hashes["big"]))
+	if err == nil {
+		t.Fatal("module requiring more memory than limit should not be loaded")
+	}
+	if len(resolver.ListComponents().Transformers) != 0 {
+		t.Fatal("wasm transformer should not be listed without modules")
+	}
+}
+
+func TestWasmLimits(t *testing.T) {
+	message := "signature mismatch"
+	resolver, hashes := newTestWasmResolver(t, map[string]testWasmModule{
+		"hang":      {memoryPages: 1, alloc: allocInput(), forward: hang(), reverse: hang()},
+		"grow":      {memoryPages: 1, alloc: allocInput(), forward: growByInputLength(), reverse: growByInputLength()},
+		"bad-alloc": {memoryPages: 1, alloc: i32Const(-16), forward: returnInput(), reverse: returnInput()},
+		// output is right after the end of single page
+		"bad-result": {memoryPages: 1, alloc: allocInput(), forward: i64Const(65536<<32 | 16), reverse: returnInput()},
+		"reject": {
+			memoryPages: 1,
+			alloc:       allocInput(),
+			forward:     failWithMessage(len(message), WasmErrorCode),
+			reverse:     failWithMessage(len(message), WasmIntegrityErrorCode),
+			data:        []byte(message),
+		},
+	})
+
+	tests := []struct {
+		name      string
+		input     string
+		error     string
+		integrity bool
+	}{
+		{"hang", "data", "exceeded time limit", false},
+		{"grow", strings.Repeat("x", testWasmMemoryLimitPages), "failed with code -1", false},
+		{"bad-alloc", "data", "allocated memory out of range", false},
+		{"bad-result", "data", "returned output out of range", false},
+		{"reject", "data", "failed with code -1: " + message, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transformer, err := resolver.ResolveTransformer(WasmTransformerName, []byte(hashes[test.name]))
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			start := time.Now()
+			_, err = transformer.ForwardTransform([]byte(test.input))
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Fatalf("expected error with %q, got %v", test.error, err)
+			}
+			var integrityErr *transformers.IntegrityError
+			if errors.As(err, &integrityErr) {
+				t.Fatalf("generic failure is reported as integrity error: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*testWasmTimeout {
+				t.Fatalf("transform took %v", elapsed)
+			}
+		})
+	}
+
+	// growing within limit works
+	transformer, _ := resolver.ResolveTransformer(WasmTransformerName, []byte(hashes["grow"]))
+	_, err := transformer.ForwardTransform([]byte(strings.Repeat("x", testWasmMemoryLimitPages-1)))
+	if err != nil {
+		t.Fatalf("growing memory within limit should work: %+v", err)
+	}
+}
+
+func TestWasmIntegrityError(t *testing.T) {
+	message := "signature mismatch"
+	resolver, hashes := newTestWasmResolver(t, map[string]testWasmModule{
+		"reject": {
+			memoryPages: 1,
+			alloc:       allocInput(),
+			forward:     failWithMessage(len(message), WasmErrorCode),
+			reverse:     failWithMessage(len(message), WasmIntegrityErrorCode),
+			data:        []byte(message),
+		},
+	})
+	transformer, err := resolver.ResolveTransformer(WasmTransformerName, []byte(hashes["reject"]))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err = transformer.ReverseTransform([]byte("data"))
+	var integrityErr *transformers.IntegrityError
+	if !errors.As(err, &integrityErr) || integrityErr.Component != WasmTransformerName {
+		t.Fatalf("expected integrity error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), message) {
+		t.Fatalf("error should contain message of module: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/takahawk/shadownet/logger"
@@ -13,11 +14,45 @@ import (
 // DbDriverNameSqlite3 is name of SQLite3 driver
 const DbDriverNameSqlite3 = "sqlite3"
 
-var migrations = []string{
-	`CREATE TABLE pipelines (
+// migration is single change of database schema. Version of schema is taken
+// from PRAGMA schema_version, so every migration should make exactly one
+// schema change
+type migration struct {
+	statement string
+	// upgrade is optional function that migrates data after statement in the
+	// same transaction
+	upgrade func(ss *sqliteStorage, tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{statement: `CREATE TABLE pipelines (
 		name TEXT PRIMARY KEY,
 		json TEXT NOT NULL
-	)`,
+	)`},
+	{statement: `CREATE TABLE keys (
+		name TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		public_key BLOB NOT NULL UNIQUE,
+		private_key BLOB NOT NULL
+	)`},
+	{statement: `CREATE TABLE accounts (
+		name TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		client_secret TEXT NOT NULL,
+		access_token TEXT NOT NULL,
+		refresh_token TEXT NOT NULL,
+		expiry INTEGER NOT NULL
+	)`},
+	{statement: `CREATE TABLE secrets (
+		name TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`},
+	{
+		// version 0 is legacy pipeline specification format
+		statement: `ALTER TABLE pipelines ADD COLUMN version INTEGER NOT NULL DEFAULT 0`,
+		upgrade:   upgradePipelineSpecs,
+	},
 }
 
 type sqliteStorage struct {
@@ -90,7 +125,7 @@ func (ss *sqliteStorage) SavePipelineSpec(spec *models.PipelineSpec) error {
 		ss.logger.Error("Empty name of pipeline specification")
 		return errors.New("empty name of pipeline specificafion")
 	}
-	_, err = ss.db.Exec("INSERT INTO pipelines (name, json) VALUES (?, ?)", spec.Name, pipelineJSON)
+	_, err = ss.db.Exec("INSERT INTO pipelines (name, json, version) VALUES (?, ?, ?)", spec.Name, pipelineJSON, spec.Version)
 	if err != nil {
 		ss.logger.Errorf("Error saving pipeline: %+v", err)
 		// mb more verbose logging?
@@ -128,7 +163,7 @@ func (ss *sqliteStorage) UpdatePipelineSpec(spec *models.PipelineSpec) error {
 		return err
 	}
 	// TODO: return error if pipeline is not exists
-	_, err = ss.db.Exec("UPDATE pipelines SET json = ? WHERE name = ?", spec.Name, pipelineJSON)
+	_, err = ss.db.Exec("UPDATE pipelines SET json = ?, version = ? WHERE name = ?", pipelineJSON, spec.Version, spec.Name)
 	if err != nil {
 		ss.logger.Errorf("Error updating pipeline: %+v", err)
 		// mb more verbose logging?
@@ -149,6 +184,223 @@ func (ss *sqliteStorage) DeletePipelineSpec(name string) error {
 	return nil
 }
 
+// ListKeys returns all key pairs stored in SQLite database
+func (ss *sqliteStorage) ListKeys() ([]*models.Key, error) {
+	result := make([]*models.Key, 0)
+	rows, err := ss.db.Query("SELECT name, type, public_key, private_key FROM keys")
+	if err != nil {
+		ss.logger.Errorf("Error getting keys: %+v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key models.Key
+		err = rows.Scan(&key.Name, &key.Type, &key.PublicKey, &key.PrivateKey)
+		if err != nil {
+			ss.logger.Errorf("Error getting key: %+v", err)
+			return nil, err
+		}
+		result = append(result, &key)
+	}
+
+	return result, nil
+}
+
+// SaveKey saves key pair in SQL database
+func (ss *sqliteStorage) SaveKey(key *models.Key) error {
+	if key.Name == "" {
+		ss.logger.Error("Empty name of key")
+		return errors.New("empty name of key")
+	}
+	_, err := ss.db.Exec("INSERT INTO keys (name, type, public_key, private_key) VALUES (?, ?, ?, ?)",
+		key.Name, key.Type, key.PublicKey, key.PrivateKey)
+	if err != nil {
+		ss.logger.Errorf("Error saving key: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// LoadKey makes query to SQLite to get key pair by name
+func (ss *sqliteStorage) LoadKey(name string) (*models.Key, error) {
+	row := ss.db.QueryRow("SELECT name, type, public_key, private_key FROM keys WHERE name = ?", name)
+	return ss.scanKey(row)
+}
+
+// LoadKeyByPublicKey makes query to SQLite to get key pair by public key
+func (ss *sqliteStorage) LoadKeyByPublicKey(publicKey []byte) (*models.Key, error) {
+	row := ss.db.QueryRow("SELECT name, type, public_key, private_key FROM keys WHERE public_key = ?", publicKey)
+	return ss.scanKey(row)
+}
+
+// DeleteKey makes query to remove key pair with a given name from database
+func (ss *sqliteStorage) DeleteKey(name string) error {
+	_, err := ss.db.Exec("DELETE FROM keys WHERE name = ?", name)
+	if err != nil {
+		ss.logger.Errorf("Error deleting key: %+v", err)
+		return err
+	}
+	return nil
+}
+
+func (ss *sqliteStorage) scanKey(row *sql.Row) (*models.Key, error) {
+	var key models.Key
+	err := row.Scan(&key.Name, &key.Type, &key.PublicKey, &key.PrivateKey)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			ss.logger.Errorf("Error getting key: %+v", err)
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAccounts returns all OAuth accounts stored in SQLite database
+func (ss *sqliteStorage) ListAccounts() ([]*models.OAuthAccount, error) {
+	result := make([]*models.OAuthAccount, 0)
+	rows, err := ss.db.Query("SELECT name, provider, client_id, client_secret, access_token, refresh_token, expiry FROM accounts")
+	if err != nil {
+		ss.logger.Errorf("Error getting accounts: %+v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		account, err := ss.scanAccount(rows)
+		if err != nil {
+			ss.logger.Errorf("Error getting account: %+v", err)
+			return nil, err
+		}
+		result = append(result, account)
+	}
+
+	return result, nil
+}
+
+// SaveAccount saves OAuth account in SQL database replacing existing one with
+// the same name
+func (ss *sqliteStorage) SaveAccount(account *models.OAuthAccount) error {
+	if account.Name == "" {
+		ss.logger.Error("Empty name of account")
+		return errors.New("empty name of account")
+	}
+	var expiry int64
+	if !account.Expiry.IsZero() {
+		expiry = account.Expiry.Unix()
+	}
+	_, err := ss.db.Exec(`INSERT OR REPLACE INTO accounts
+		(name, provider, client_id, client_secret, access_token, refresh_token, expiry)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		account.Name, account.Provider, account.ClientID, account.ClientSecret,
+		account.AccessToken, account.RefreshToken, expiry)
+	if err != nil {
+		ss.logger.Errorf("Error saving account: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// LoadAccount makes query to SQLite to get OAuth account by name
+func (ss *sqliteStorage) LoadAccount(name string) (*models.OAuthAccount, error) {
+	row := ss.db.QueryRow("SELECT name, provider, client_id, client_secret, access_token, refresh_token, expiry FROM accounts WHERE name = ?", name)
+	account, err := ss.scanAccount(row)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			ss.logger.Errorf("Error getting account: %+v", err)
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+// DeleteAccount makes query to remove OAuth account with a given name from
+// database
+func (ss *sqliteStorage) DeleteAccount(name string) error {
+	_, err := ss.db.Exec("DELETE FROM accounts WHERE name = ?", name)
+	if err != nil {
+		ss.logger.Errorf("Error deleting account: %+v", err)
+		return err
+	}
+	return nil
+}
+
+func (ss *sqliteStorage) scanAccount(row interface{ Scan(dest ...any) error }) (*models.OAuthAccount, error) {
+	var account models.OAuthAccount
+	var expiry int64
+	err := row.Scan(&account.Name, &account.Provider, &account.ClientID, &account.ClientSecret,
+		&account.AccessToken, &account.RefreshToken, &expiry)
+	if err != nil {
+		return nil, err
+	}
+	if expiry != 0 {
+		account.Expiry = time.Unix(expiry, 0)
+	}
+	return &account, nil
+}
+
+// ListSecrets returns all secrets stored in SQLite database
+func (ss *sqliteStorage) ListSecrets() ([]*models.Secret, error) {
+	result := make([]*models.Secret, 0)
+	rows, err := ss.db.Query("SELECT name, value FROM secrets")
+	if err != nil {
+		ss.logger.Errorf("Error getting secrets: %+v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var secret models.Secret
+		err = rows.Scan(&secret.Name, &secret.Value)
+		if err != nil {
+			ss.logger.Errorf("Error getting secret: %+v", err)
+			return nil, err
+		}
+		result = append(result, &secret)
+	}
+
+	return result, nil
+}
+
+// SaveSecret saves secret in SQL database replacing existing one with the
+// same name
+func (ss *sqliteStorage) SaveSecret(secret *models.Secret) error {
+	if secret.Name == "" {
+		ss.logger.Error("Empty name of secret")
+		return errors.New("empty name of secret")
+	}
+	_, err := ss.db.Exec("INSERT OR REPLACE INTO secrets (name, value) VALUES (?, ?)", secret.Name, secret.Value)
+	if err != nil {
+		ss.logger.Errorf("Error saving secret: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// LoadSecret makes query to SQLite to get secret by name
+func (ss *sqliteStorage) LoadSecret(name string) (*models.Secret, error) {
+	row := ss.db.QueryRow("SELECT name, value FROM secrets WHERE name = ?", name)
+	var secret models.Secret
+	err := row.Scan(&secret.Name, &secret.Value)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			ss.logger.Errorf("Error getting secret: %+v", err)
+		}
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// DeleteSecret makes query to remove secret with a given name from database
+func (ss *sqliteStorage) DeleteSecret(name string) error {
+	_, err := ss.db.Exec("DELETE FROM secrets WHERE name = ?", name)
+	if err != nil {
+		ss.logger.Errorf("Error deleting secret: %+v", err)
+		return err
+	}
+	return nil
+}
+
 func (ss *sqliteStorage) getSchemaVersion() (int, error) {
 	row := ss.db.QueryRow("PRAGMA schema_version")
 	var version int
@@ -174,8 +426,8 @@ func (ss *sqliteStorage) runMigrations() error {
 	ss.logger.Infof("Updating database schema from version %d to %d", version, len(migrations))
 
 	for i := version; i < len(migrations); i++ {
-		ss.logger.Infof("Applying migration %d: %s", i, migrations[i])
-		_, err := ss.db.Exec(migrations[i])
+		ss.logger.Infof("Applying migration %d: %s", i, migrations[i].statement)
+		err := ss.applyMigration(migrations[i])
 		if err != nil {
 			ss.logger.Errorf("%+v", err)
 			return err
@@ -184,3 +436,66 @@ func (ss *sqliteStorage) runMigrations() error {
 
 	return nil
 }
+
+// applyMigration runs migration in transaction, so that schema version is
+// changed only if data is migrated too
+func (ss *sqliteStorage) applyMigration(m migration) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(m.statement)
+	if err == nil && m.upgrade != nil {
+		err = m.upgrade(ss, tx)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// upgradePipelineSpecs rewrites pipeline specifications stored in legacy
+// format in the current one. Specs that can't be parsed are logged and left
+// as is, so that single broken spec doesn't prevent gateway from starting
+func upgradePipelineSpecs(ss *sqliteStorage, tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT name, json FROM pipelines")
+	if err != nil {
+		return err
+	}
+	upgraded := make(map[string][]byte)
+	for rows.Next() {
+		var name, pipelineJson string
+		err = rows.Scan(&name, &pipelineJson)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		var spec models.PipelineSpec
+		err = json.Unmarshal([]byte(pipelineJson), &spec)
+		if err != nil {
+			ss.logger.Errorf("Skipping upgrade of pipeline %s: %+v", name, err)
+			continue
+		}
+		upgradedJSON, err := json.Marshal(&spec)
+		if err != nil {
+			ss.logger.Errorf("Skipping upgrade of pipeline %s: %+v", name, err)
+			continue
+		}
+		upgraded[name] = upgradedJSON
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for name, pipelineJSON := range upgraded {
+		ss.logger.Infof("Upgrading pipeline %s to version %d", name, models.PipelineSpecVersion)
+		_, err = tx.Exec("UPDATE pipelines SET json = ?, version = ? WHERE name = ?", pipelineJSON, models.PipelineSpecVersion, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package storages
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/models"
+)
+
+// TestUpgradeLegacyPipelineSpecs opens database made before pipeline spec
+// version was added and checks that legacy specs are upgraded once
+func TestUpgradeLegacyPipelineSpecs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "shadownet.db")
+	db, err := sql.Open(DbDriverNameSqlite3, filename)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	for _, m := range migrations[:4] {
+		if _, err := db.Exec(m.statement); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	var version int
+	db.QueryRow("PRAGMA schema_version").Scan(&version)
+	if version != 4 {
+		t.Fatalf("seeded database should be at schema version 4, got %d", version)
+	}
+	legacyJSON := `{"name":"legacy","components":[{"name":"aes","params":["c2hhZG93bmV0"],"isParamsBase64d":true},{"name":"web","params":[]}]}`
+	brokenJSON := `{"name":"broken","components":`
+	_, err = db.Exec("INSERT INTO pipelines (name, json) VALUES (?, ?), (?, ?)", "legacy", legacyJSON, "broken", brokenJSON)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	db.Close()
+
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	storage, err := NewSqliteStorage(filename, log)
+	if err != nil {
+		t.Fatalf("broken spec should not prevent migration: %+v", err)
+	}
+	ss := storage.(*sqliteStorage)
+	version, _ = ss.getSchemaVersion()
+	if version != len(migrations) {
+		t.Fatalf("expected schema version %d, got %d", len(migrations), version)
+	}
+
+	spec, err := storage.LoadPipelineSpec("legacy")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	param := spec.Components[0].Params[0]
+	decoded, err := param.Decode(func(string) (string, error) { return "", nil })
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expected, _ := base64.StdEncoding.DecodeString("c2hhZG93bmV0")
+	if param.Type != models.ParamValueBase64 || !bytes.Equal(decoded, expected) {
+		t.Fatalf("legacy param is upgraded to %+v", param)
+	}
+
+	var pipelineVersion int
+	var pipelineJSON string
+	ss.db.QueryRow("SELECT version FROM pipelines WHERE name = ?", "legacy").Scan(&pipelineVersion)
+	if pipelineVersion != models.PipelineSpecVersion {
+		t.Fatalf("upgraded spec should have version %d, got %d", models.PipelineSpecVersion, pipelineVersion)
+	}
+	ss.db.QueryRow("SELECT json, version FROM pipelines WHERE name = ?", "broken").Scan(&pipelineJSON, &pipelineVersion)
+	if pipelineJSON != brokenJSON || pipelineVersion != 0 {
+		t.Fatalf("broken spec should be left as is, got version %d: %s", pipelineVersion, pipelineJSON)
+	}
+
+	// migration is not run again
+	ss.db.Exec("UPDATE pipelines SET json = ? WHERE name = ?", legacyJSON, "legacy")
+	ss.db.Close()
+	storage, err = NewSqliteStorage(filename, log)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	storage.(*sqliteStorage).db.QueryRow("SELECT json FROM pipelines WHERE name = ?", "legacy").Scan(&pipelineJSON)
+	if pipelineJSON != legacyJSON {
+		t.Fatalf("specs should be upgraded only once, got %s", pipelineJSON)
+	}
+}
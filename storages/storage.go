@@ -5,6 +5,9 @@ import "github.com/takahawk/shadownet/models"
 // Storage
 type Storage interface {
 	PipelineStorage
+	KeyStorage
+	AccountStorage
+	SecretStorage
 }
 
 // PipelineStorage is used to persistently store pipelines in JSON form
@@ -22,3 +25,43 @@ type PipelineStorage interface {
 	// storage
 	DeletePipelineSpec(name string) error
 }
+
+// KeyStorage is used to persistently store key pairs of gateway keyring
+type KeyStorage interface {
+	// ListKeys returns slice of all keys that are exist in storage
+	ListKeys() ([]*models.Key, error)
+	// SaveKey stores key pair
+	SaveKey(key *models.Key) error
+	// LoadKey returns key pair with a given name
+	LoadKey(name string) (*models.Key, error)
+	// LoadKeyByPublicKey returns key pair with a given public key
+	LoadKeyByPublicKey(publicKey []byte) (*models.Key, error)
+	// DeleteKey removes key pair with a given name from storage
+	DeleteKey(name string) error
+}
+
+// AccountStorage is used to persistently store OAuth accounts linked to
+// gateway along with their tokens
+type AccountStorage interface {
+	// ListAccounts returns slice of all accounts that are exist in storage
+	ListAccounts() ([]*models.OAuthAccount, error)
+	// SaveAccount stores account overwriting existing one with the same name
+	SaveAccount(account *models.OAuthAccount) error
+	// LoadAccount returns account with a given name
+	LoadAccount(name string) (*models.OAuthAccount, error)
+	// DeleteAccount removes account with a given name from storage
+	DeleteAccount(name string) error
+}
+
+// SecretStorage is used to persistently store secrets pipeline
+// specifications refer to
+type SecretStorage interface {
+	// ListSecrets returns slice of all secrets that are exist in storage
+	ListSecrets() ([]*models.Secret, error)
+	// SaveSecret stores secret overwriting existing one with the same name
+	SaveSecret(secret *models.Secret) error
+	// LoadSecret returns secret with a given name
+	LoadSecret(name string) (*models.Secret, error)
+	// DeleteSecret removes secret with a given name from storage
+	DeleteSecret(name string) error
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 
+	"github.com/takahawk/shadownet/common"
 	"github.com/takahawk/shadownet/logger"
 )
 
@@ -19,6 +20,16 @@ type aesEncryptor struct {
 // AESEncryptorName is component name for AES encryptor
 const AESEncryptorName = "aes"
 
+// AESEncryptorSchema describes AES encryptor and its params
+var AESEncryptorSchema = common.ComponentSchema{
+	Name:        AESEncryptorName,
+	Description: "Encrypts data with AES-256 in CBC mode",
+	Params: []common.ParamSchema{
+		{Name: "key", Type: common.ParamTypeBytes, Description: "AES-256 key", Lengths: []int{32}, Secret: true},
+		{Name: "iv", Type: common.ParamTypeBytes, Description: "initialization vector", Lengths: []int{aes.BlockSize}},
+	},
+}
+
 // NewAESEncryptor creates new transformer that allows to encrypt and decrypt
 // data using AES algorithm.
 // Key and initialization vector must be provided.
@@ -55,6 +66,11 @@ func (ae *aesEncryptor) Name() string {
 	return AESEncryptorName
 }
 
+// Schema returns schema of AES encryptor. It is always AESEncryptorSchema
+func (ae *aesEncryptor) Schema() common.ComponentSchema {
+	return AESEncryptorSchema
+}
+
 // Params returns key and initialization vector packed into slice
 func (ae *aesEncryptor) Params() [][]byte {
 	return [][]byte{ae.key, ae.iv}
@@ -90,11 +106,24 @@ func (ae *aesEncryptor) ReverseTransform(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, &IntegrityError{
+			Component: AESEncryptorName,
+			Err:       errors.New("data length is not a multiple of block size"),
+		}
+	}
+
 	decrypted := make([]byte, len(data))
 	mode := cipher.NewCBCDecrypter(block, ae.iv)
 	mode.CryptBlocks(decrypted, data)
 	// unpadding
 	unpadding := int(decrypted[len(decrypted)-1])
+	if unpadding == 0 || unpadding > block.BlockSize() {
+		return nil, &IntegrityError{
+			Component: AESEncryptorName,
+			Err:       errors.New("invalid padding"),
+		}
+	}
 	decrypted = decrypted[:len(decrypted)-unpadding]
 
 	return decrypted, nil
@@ -0,0 +1,122 @@
+package transformers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// AESGCMEncryptorName is component name for AES-GCM encryptor
+const AESGCMEncryptorName = "aes-gcm"
+
+// AESGCMEncryptorSchema describes AES-GCM encryptor and its params
+var AESGCMEncryptorSchema = common.ComponentSchema{
+	Name:        AESGCMEncryptorName,
+	Description: "Encrypts and authenticates data with AES in GCM mode",
+	Params: []common.ParamSchema{
+		{Name: "key", Type: common.ParamTypeBytes, Description: "AES key", Lengths: []int{16, 24, 32}, Secret: true},
+	},
+}
+
+type aesGCMEncryptor struct {
+	logger logger.Logger
+	key    []byte
+}
+
+// NewAESGCMEncryptor creates new transformer that allows to encrypt and
+// decrypt data using AES in GCM mode. Fresh random nonce is generated for
+// every encryption and is stored at the beginning of encrypted data.
+// Key should be 16, 24 or 32 bytes long.
+func NewAESGCMEncryptor(logger logger.Logger, key []byte) (Transformer, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("key length should be 16, 24 or 32 bytes")
+	}
+	return &aesGCMEncryptor{
+		logger: logger,
+		key:    key,
+	}, nil
+}
+
+// NewAESGCMEncryptorWithParams is convenience function that calls
+// NewAESGCMEncryptor with the key packed into slice
+func NewAESGCMEncryptorWithParams(logger logger.Logger, params ...[]byte) (Transformer, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be 1 parameter: key")
+	}
+	return NewAESGCMEncryptor(logger, params[0])
+}
+
+// Name returns component name of AES-GCM encryptor. It is always
+// AESGCMEncryptorName
+func (age *aesGCMEncryptor) Name() string {
+	return AESGCMEncryptorName
+}
+
+// Schema returns schema of AES-GCM encryptor. It is always AESGCMEncryptorSchema
+func (age *aesGCMEncryptor) Schema() common.ComponentSchema {
+	return AESGCMEncryptorSchema
+}
+
+// Params returns key packed into slice
+func (age *aesGCMEncryptor) Params() [][]byte {
+	return [][]byte{age.key}
+}
+
+// ForwardTransform returns nonce followed by data encrypted and
+// authenticated with AES-GCM
+func (age *aesGCMEncryptor) ForwardTransform(data []byte) ([]byte, error) {
+	aead, err := age.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// ReverseTransform returns decrypted data. IntegrityError is returned if
+// data was modified after encryption
+func (age *aesGCMEncryptor) ReverseTransform(data []byte) ([]byte, error) {
+	aead, err := age.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, &IntegrityError{
+			Component: AESGCMEncryptorName,
+			Err:       errors.New(fmt.Sprintf("data is too short: %d bytes", len(data))),
+		}
+	}
+
+	nonce := data[:aead.NonceSize()]
+	decrypted, err := aead.Open(nil, nonce, data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, &IntegrityError{
+			Component: AESGCMEncryptorName,
+			Err:       err,
+		}
+	}
+
+	return decrypted, nil
+}
+
+func (age *aesGCMEncryptor) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(age.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -3,13 +3,22 @@ package transformers
 import (
 	"encoding/base64"
 	"errors"
+	"io"
 
+	"github.com/takahawk/shadownet/common"
 	"github.com/takahawk/shadownet/logger"
 )
 
 // Base64TransformerName is component name of base64 transformer
 const Base64TransformerName = "base64"
 
+// Base64TransformerSchema describes base64 transformer and its params
+var Base64TransformerSchema = common.ComponentSchema{
+	Name:        Base64TransformerName,
+	Description: "Encodes data with base64",
+	Params:      []common.ParamSchema{},
+}
+
 type base64Transformer struct {
 	logger logger.Logger
 }
@@ -38,6 +47,11 @@ func (b64t *base64Transformer) Name() string {
 	return Base64TransformerName
 }
 
+// Schema returns schema of base64 transformer. It is always Base64TransformerSchema
+func (b64t *base64Transformer) Schema() common.ComponentSchema {
+	return Base64TransformerSchema
+}
+
 // Params returns empty slice just to be with accordance with general interface
 func (b64t *base64Transformer) Params() [][]byte {
 	return nil
@@ -60,3 +74,14 @@ func (b64t *base64Transformer) ReverseTransform(data []byte) ([]byte, error) {
 
 	return decoded[:n], nil
 }
+
+// ForwardTransformWriter returns writer that encodes everything written to it
+// using base64 encoding
+func (b64t *base64Transformer) ForwardTransformWriter(w io.Writer) (io.WriteCloser, error) {
+	return base64.NewEncoder(base64.StdEncoding, w), nil
+}
+
+// ReverseTransformReader returns reader that decodes base64'd data from r
+func (b64t *base64Transformer) ReverseTransformReader(r io.Reader) (io.Reader, error) {
+	return base64.NewDecoder(base64.StdEncoding, r), nil
+}
@@ -0,0 +1,306 @@
+package transformers
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+const (
+	// GzipCompressorName is component name of gzip compressor
+	GzipCompressorName = "gzip"
+	// ZlibCompressorName is component name of zlib compressor
+	ZlibCompressorName = "zlib"
+	// FlateCompressorName is component name of raw DEFLATE compressor
+	FlateCompressorName = "flate"
+	// AutoCompressorName is component name of compressor that compresses
+	// data only if it makes it smaller
+	AutoCompressorName = "compress-auto"
+)
+
+// MaxDecompressedSize is the largest size of decompressed data. Downloaded
+// data is untrusted, so it guards gateway against decompression bombs
+const MaxDecompressedSize = 256 << 20
+
+// Schemas of compressors. Level is needed only for compression
+var (
+	GzipCompressorSchema  = compressorSchema(GzipCompressorName, "Compresses data with gzip", flate.DefaultCompression)
+	ZlibCompressorSchema  = compressorSchema(ZlibCompressorName, "Compresses data with zlib", flate.DefaultCompression)
+	FlateCompressorSchema = compressorSchema(FlateCompressorName, "Compresses data with raw DEFLATE", flate.DefaultCompression)
+	AutoCompressorSchema  = compressorSchema(AutoCompressorName, "Compresses data with DEFLATE if it makes it smaller", flate.BestCompression)
+)
+
+const (
+	// autoCompressorStored marks data left as is by auto compressor
+	autoCompressorStored = 0
+	// autoCompressorDeflated marks data compressed with DEFLATE by auto
+	// compressor
+	autoCompressorDeflated = 1
+)
+
+type compressor struct {
+	logger logger.Logger
+	name   string
+	level  int
+}
+
+// NewGzipCompressor returns transformer that compresses data with gzip using
+// a given level (from flate.HuffmanOnly to flate.BestCompression)
+func NewGzipCompressor(logger logger.Logger, level int) (Transformer, error) {
+	return newCompressor(logger, GzipCompressorName, level)
+}
+
+// NewZlibCompressor returns transformer that compresses data with zlib using
+// a given level (from flate.HuffmanOnly to flate.BestCompression)
+func NewZlibCompressor(logger logger.Logger, level int) (Transformer, error) {
+	return newCompressor(logger, ZlibCompressorName, level)
+}
+
+// NewFlateCompressor returns transformer that compresses data with raw
+// DEFLATE using a given level (from flate.HuffmanOnly to
+// flate.BestCompression)
+func NewFlateCompressor(logger logger.Logger, level int) (Transformer, error) {
+	return newCompressor(logger, FlateCompressorName, level)
+}
+
+// NewAutoCompressor returns transformer that compresses data with DEFLATE
+// using a given level, but keeps it as is if compression doesn't make it
+// smaller. The choice is stored in the first byte of transformed data
+func NewAutoCompressor(logger logger.Logger, level int) (Transformer, error) {
+	return newCompressor(logger, AutoCompressorName, level)
+}
+
+// NewGzipCompressorWithParams is convenience function that calls
+// NewGzipCompressor. Compression level in decimal form is the only optional
+// parameter
+func NewGzipCompressorWithParams(logger logger.Logger, params ...[]byte) (Transformer, error) {
+	return newCompressorWithParams(logger, GzipCompressorName, flate.DefaultCompression, params...)
+}
+
+// NewZlibCompressorWithParams is convenience function that calls
+// NewZlibCompressor. Compression level in decimal form is the only optional
+// parameter
+func NewZlibCompressorWithParams(logger logger.Logger, params ...[]byte) (Transformer, error) {
+	return newCompressorWithParams(logger, ZlibCompressorName, flate.DefaultCompression, params...)
+}
+
+// NewFlateCompressorWithParams is convenience function that calls
+// NewFlateCompressor. Compression level in decimal form is the only optional
+// parameter
+func NewFlateCompressorWithParams(logger logger.Logger, params ...[]byte) (Transformer, error) {
+	return newCompressorWithParams(logger, FlateCompressorName, flate.DefaultCompression, params...)
+}
+
+// NewAutoCompressorWithParams is convenience function that calls
+// NewAutoCompressor. Compression level in decimal form is the only optional
+// parameter, best compression is used by default
+func NewAutoCompressorWithParams(logger logger.Logger, params ...[]byte) (Transformer, error) {
+	return newCompressorWithParams(logger, AutoCompressorName, flate.BestCompression, params...)
+}
+
+func newCompressor(logger logger.Logger, name string, level int) (Transformer, error) {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return nil, errors.New(fmt.Sprintf("invalid compression level: %d", level))
+	}
+	return &compressor{
+		logger: logger,
+		name:   name,
+		level:  level,
+	}, nil
+}
+
+func compressorSchema(name string, description string, defaultLevel int) common.ComponentSchema {
+	return common.ComponentSchema{
+		Name:        name,
+		Description: description,
+		Params: []common.ParamSchema{
+			{Name: "level", Type: common.ParamTypeInt, Description: "compression level from -2 (Huffman only) to 9, not put into URL", Optional: true, Default: strconv.Itoa(defaultLevel)},
+		},
+	}
+}
+
+func newCompressorWithParams(logger logger.Logger, name string, defaultLevel int, params ...[]byte) (Transformer, error) {
+	switch len(params) {
+	case 0:
+		return newCompressor(logger, name, defaultLevel)
+	case 1:
+		level, err := strconv.Atoi(string(params[0]))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid compression level: %s", params[0]))
+		}
+		return newCompressor(logger, name, level)
+	default:
+		return nil, errors.New(fmt.Sprintf("%s compressor accepts at most 1 parameter: level", name))
+	}
+}
+
+// Name returns component name of compressor
+func (c *compressor) Name() string {
+	return c.name
+}
+
+// Schema returns schema of compressor
+func (c *compressor) Schema() common.ComponentSchema {
+	switch c.name {
+	case GzipCompressorName:
+		return GzipCompressorSchema
+	case ZlibCompressorName:
+		return ZlibCompressorSchema
+	case FlateCompressorName:
+		return FlateCompressorSchema
+	default:
+		return AutoCompressorSchema
+	}
+}
+
+// Params returns nothing. Compression level isn't needed for decompression,
+// so it is not kept in ShadowNet URL
+func (c *compressor) Params() [][]byte {
+	return nil
+}
+
+// ForwardTransform returns compressed data
+func (c *compressor) ForwardTransform(data []byte) ([]byte, error) {
+	if c.name == AutoCompressorName {
+		return c.autoCompress(data)
+	}
+
+	var buf bytes.Buffer
+	w, err := c.ForwardTransformWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReverseTransform returns decompressed data. Error is returned if it is
+// larger than MaxDecompressedSize
+func (c *compressor) ReverseTransform(data []byte) ([]byte, error) {
+	r, err := c.ReverseTransformReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// ForwardTransformWriter returns writer compressing everything written to it
+func (c *compressor) ForwardTransformWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c.name {
+	case GzipCompressorName:
+		return gzip.NewWriterLevel(w, c.level)
+	case ZlibCompressorName:
+		return zlib.NewWriterLevel(w, c.level)
+	case FlateCompressorName:
+		return flate.NewWriter(w, c.level)
+	default:
+		// whether data shrinks is known only after compressing all of it
+		return &bufferedForwardWriter{
+			transformer: c,
+			w:           w,
+		}, nil
+	}
+}
+
+// ReverseTransformReader returns reader decompressing data read from r. Reader
+// fails if decompressed data is larger than MaxDecompressedSize
+func (c *compressor) ReverseTransformReader(r io.Reader) (io.Reader, error) {
+	switch c.name {
+	case GzipCompressorName:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return c.limit(gr), nil
+	case ZlibCompressorName:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return c.limit(zr), nil
+	case FlateCompressorName:
+		return c.limit(flate.NewReader(r)), nil
+	default:
+		var flag [1]byte
+		_, err := io.ReadFull(r, flag[:])
+		if err != nil {
+			return nil, &IntegrityError{Component: c.name, Err: err}
+		}
+		switch flag[0] {
+		case autoCompressorStored:
+			return r, nil
+		case autoCompressorDeflated:
+			return c.limit(flate.NewReader(r)), nil
+		default:
+			return nil, &IntegrityError{
+				Component: c.name,
+				Err:       errors.New(fmt.Sprintf("unknown compression flag: %d", flag[0])),
+			}
+		}
+	}
+}
+
+// limit returns reader that fails after MaxDecompressedSize bytes are read
+// from r
+func (c *compressor) limit(r io.Reader) io.Reader {
+	return &decompressedSizeLimiter{
+		name: c.name,
+		r:    io.LimitReader(r, MaxDecompressedSize+1),
+	}
+}
+
+// decompressedSizeLimiter counts decompressed bytes. Underlying reader is
+// limited to one byte more than allowed, so that exceeding data is detected
+// without decompressing the rest of it
+type decompressedSizeLimiter struct {
+	name string
+	r    io.Reader
+	read int64
+}
+
+func (dsl *decompressedSizeLimiter) Read(p []byte) (int, error) {
+	n, err := dsl.r.Read(p)
+	dsl.read += int64(n)
+	if dsl.read > MaxDecompressedSize {
+		return 0, errors.New(fmt.Sprintf("%s: decompressed data is larger than %d bytes", dsl.name, MaxDecompressedSize))
+	}
+	return n, err
+}
+
+func (c *compressor) autoCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(autoCompressorDeflated)
+	w, err := flate.NewWriter(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if buf.Len() >= len(data)+1 {
+		c.logger.Infof("Compression doesn't make data smaller, storing it as is")
+		return append([]byte{autoCompressorStored}, data...), nil
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,87 @@
+package transformers
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/takahawk/shadownet/logger"
+)
+
+func TestCompressorRoundTrip(t *testing.T) {
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	data := bytes.Repeat([]byte("shadownet "), 1000)
+	for _, name := range []string{GzipCompressorName, ZlibCompressorName, FlateCompressorName, AutoCompressorName} {
+		t.Run(name, func(t *testing.T) {
+			compressor, err := newCompressor(log, name, flate.BestCompression)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			compressed, err := compressor.ForwardTransform(data)
+			if err != nil {
+				t.Fatalf("compression failed: %+v", err)
+			}
+			decompressed, err := compressor.ReverseTransform(compressed)
+			if err != nil {
+				t.Fatalf("decompression failed: %+v", err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Fatal("decompressed data differs")
+			}
+		})
+	}
+}
+
+func TestCompressorDecompressionBomb(t *testing.T) {
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	for _, name := range []string{GzipCompressorName, ZlibCompressorName, FlateCompressorName, AutoCompressorName} {
+		t.Run(name, func(t *testing.T) {
+			transformer, err := newCompressor(log, name, flate.BestCompression)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			var buf bytes.Buffer
+			if name == AutoCompressorName {
+				buf.WriteByte(autoCompressorDeflated)
+				w, _ := flate.NewWriter(&buf, flate.BestCompression)
+				writeZeros(t, w, MaxDecompressedSize+1)
+			} else {
+				w, _ := transformer.(StreamTransformer).ForwardTransformWriter(&buf)
+				writeZeros(t, w, MaxDecompressedSize+1)
+			}
+
+			_, err = transformer.ReverseTransform(buf.Bytes())
+			if err == nil || !strings.Contains(err.Error(), "decompressed data is larger") {
+				t.Fatalf("decompression bomb should be rejected, got %v", err)
+			}
+
+			r, err := transformer.(StreamTransformer).ReverseTransformReader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			n, err := io.Copy(io.Discard, r)
+			if err == nil {
+				t.Fatal("decompression bomb should be rejected by reader")
+			}
+			if n > MaxDecompressedSize {
+				t.Fatalf("%d bytes are read, more than limit", n)
+			}
+		})
+	}
+}
+
+func writeZeros(t *testing.T, w io.WriteCloser, size int) {
+	zeros := make([]byte, 1<<20)
+	for size > 0 {
+		n := min(size, len(zeros))
+		if _, err := w.Write(zeros[:n]); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		size -= n
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package transformers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// Ed25519SignerName is component name for Ed25519 signer
+const Ed25519SignerName = "ed25519"
+
+// Ed25519SignerSchema describes Ed25519 signer and its params
+var Ed25519SignerSchema = common.ComponentSchema{
+	Name:        Ed25519SignerName,
+	Description: "Signs data with Ed25519 key from gateway keyring",
+	Params: []common.ParamSchema{
+		{Name: "publicKey", Type: common.ParamTypeBytes, Description: "public key of signer", Lengths: []int{ed25519.PublicKeySize}},
+	},
+}
+
+// Verifier is Transformer that checks authenticity of data during reverse
+// transformation
+type Verifier interface {
+	Transformer
+	// VerifiedBy returns public key that data was successfully verified with
+	// during the last reverse transformation or nil if it wasn't
+	VerifiedBy() []byte
+}
+
+type ed25519Signer struct {
+	logger     logger.Logger
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+	verified   bool
+}
+
+// NewEd25519Signer creates new transformer that signs data with Ed25519
+// private key prepending signature to it, and verifies the signature with a
+// given public key during reverse transformation. Private key is required
+// only for signing and should be set with SetPrivateKey
+func NewEd25519Signer(logger logger.Logger, publicKey []byte) (KeyHolder, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.New(fmt.Sprintf("public key length should be %d bytes", ed25519.PublicKeySize))
+	}
+	return &ed25519Signer{
+		logger:    logger,
+		publicKey: publicKey,
+	}, nil
+}
+
+// NewEd25519SignerWithParams is convenience function that calls
+// NewEd25519Signer with public key packed into slice
+func NewEd25519SignerWithParams(logger logger.Logger, params ...[]byte) (Transformer, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be 1 parameter: public key")
+	}
+	return NewEd25519Signer(logger, params[0])
+}
+
+// Name returns component name of Ed25519 signer. It is always
+// Ed25519SignerName
+func (es *ed25519Signer) Name() string {
+	return Ed25519SignerName
+}
+
+// Schema returns schema of Ed25519 signer. It is always Ed25519SignerSchema
+func (es *ed25519Signer) Schema() common.ComponentSchema {
+	return Ed25519SignerSchema
+}
+
+// Params returns public key packed into slice
+func (es *ed25519Signer) Params() [][]byte {
+	return [][]byte{es.publicKey}
+}
+
+// PublicKeys returns public key packed into slice
+func (es *ed25519Signer) PublicKeys() [][]byte {
+	return [][]byte{es.publicKey}
+}
+
+// SetPrivateKey provides private key used for signing. Both full private key
+// and its seed are accepted
+func (es *ed25519Signer) SetPrivateKey(publicKey []byte, privateKey []byte) error {
+	var key ed25519.PrivateKey
+	switch len(privateKey) {
+	case ed25519.PrivateKeySize:
+		key = privateKey
+	case ed25519.SeedSize:
+		key = ed25519.NewKeyFromSeed(privateKey)
+	default:
+		return errors.New("invalid private key length")
+	}
+	if !bytes.Equal(key.Public().(ed25519.PublicKey), es.publicKey) || !bytes.Equal(publicKey, es.publicKey) {
+		return errors.New("private key doesn't match public key")
+	}
+	es.privateKey = key
+	return nil
+}
+
+// VerifiedBy returns public key if the last reverse transformation
+// succeeded
+func (es *ed25519Signer) VerifiedBy() []byte {
+	if !es.verified {
+		return nil
+	}
+	return es.publicKey
+}
+
+// ForwardTransform returns signature followed by data
+func (es *ed25519Signer) ForwardTransform(data []byte) ([]byte, error) {
+	if es.privateKey == nil {
+		return nil, errors.New("there is no private key for signing")
+	}
+	signature := ed25519.Sign(es.privateKey, data)
+	return append(signature, data...), nil
+}
+
+// ReverseTransform verifies signature and returns data without it.
+// IntegrityError is returned if signature doesn't match
+func (es *ed25519Signer) ReverseTransform(data []byte) ([]byte, error) {
+	es.verified = false
+	if len(data) < ed25519.SignatureSize {
+		return nil, &IntegrityError{
+			Component: Ed25519SignerName,
+			Err:       errors.New(fmt.Sprintf("data is too short: %d bytes", len(data))),
+		}
+	}
+
+	signature := data[:ed25519.SignatureSize]
+	data = data[ed25519.SignatureSize:]
+	if !ed25519.Verify(es.publicKey, data, signature) {
+		return nil, &IntegrityError{
+			Component: Ed25519SignerName,
+			Err:       errors.New("signature doesn't match"),
+		}
+	}
+
+	es.verified = true
+	return data, nil
+}
@@ -0,0 +1,25 @@
+package transformers
+
+import (
+	"fmt"
+)
+
+// IntegrityError is returned by transformers when data given for reverse
+// transformation was tampered with, truncated or otherwise corrupted
+type IntegrityError struct {
+	// Component is name of transformer that has detected the problem
+	Component string
+	// Err is the underlying error if any
+	Err error
+}
+
+func (ie *IntegrityError) Error() string {
+	if ie.Err == nil {
+		return fmt.Sprintf("%s: integrity check failed", ie.Component)
+	}
+	return fmt.Sprintf("%s: integrity check failed: %v", ie.Component, ie.Err)
+}
+
+func (ie *IntegrityError) Unwrap() error {
+	return ie.Err
+}
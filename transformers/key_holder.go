@@ -0,0 +1,13 @@
+package transformers
+
+// KeyHolder is Transformer that relies on private keys kept outside of
+// ShadowNet URL (e.g. in gateway keyring). Only public keys are part of its
+// params, private ones should be provided separately before transformation
+type KeyHolder interface {
+	Transformer
+	// PublicKeys returns public keys which private counterparts can be used
+	// by transformer
+	PublicKeys() [][]byte
+	// SetPrivateKey provides private key corresponding to a given public key
+	SetPrivateKey(publicKey []byte, privateKey []byte) error
+}
@@ -0,0 +1,217 @@
+package transformers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEncryptorName is component name for passphrase encryptor
+const PassphraseEncryptorName = "passphrase"
+
+// PassphraseEncryptorSchema describes passphrase encryptor and its params
+var PassphraseEncryptorSchema = common.ComponentSchema{
+	Name:        PassphraseEncryptorName,
+	Description: "Encrypts data with key derived from passphrase. Passphrase is asked on download",
+	Params: []common.ParamSchema{
+		{Name: "passphrase", Type: common.ParamTypeString, Description: "passphrase, not put into URL", Secret: true, Optional: true},
+	},
+}
+
+const (
+	// PassphraseFormatVersion is version of encrypted data layout written by
+	// passphrase encryptor
+	PassphraseFormatVersion = 1
+	// PassphraseSaltLength is length of random salt used for key derivation
+	PassphraseSaltLength = 16
+	// PassphraseScryptLogN is base 2 logarithm of scrypt CPU/memory cost
+	// parameter used for new uploads
+	PassphraseScryptLogN = 15
+	// PassphraseScryptR is scrypt block size parameter used for new uploads
+	PassphraseScryptR = 8
+	// PassphraseScryptP is scrypt parallelization parameter used for new
+	// uploads
+	PassphraseScryptP = 1
+)
+
+// upper bound for scrypt cost read from downloaded data, so that malicious
+// content can't make gateway to allocate more than 128*r*N = 256 MiB. r and p
+// are accepted only if they are the same as written by ForwardTransform
+const maxPassphraseScryptLogN = 18
+
+// scryptKey derives key, it is replaced in tests to check that it is not
+// called for rejected parameters
+var scryptKey = scrypt.Key
+
+// header is version, scrypt logN, r, p and salt
+const passphraseHeaderLength = 4 + PassphraseSaltLength
+
+// PassphraseTransformer is Transformer that requires passphrase which is never
+// stored in ShadowNet URL, so it should be provided separately before use
+type PassphraseTransformer interface {
+	Transformer
+	// HasPassphrase reports whether passphrase was already provided
+	HasPassphrase() bool
+	// SetPassphrase provides passphrase to be used by transformer
+	SetPassphrase(passphrase []byte)
+}
+
+type passphraseEncryptor struct {
+	logger     logger.Logger
+	passphrase []byte
+}
+
+// NewPassphraseEncryptor creates new transformer that encrypts data with
+// AES-GCM using key derived from passphrase with scrypt. Random salt and
+// scrypt parameters are stored along with encrypted data, so only passphrase
+// is needed to decrypt it. Passphrase can be nil, then it should be set
+// with SetPassphrase before transformation
+func NewPassphraseEncryptor(logger logger.Logger, passphrase []byte) PassphraseTransformer {
+	return &passphraseEncryptor{
+		logger:     logger,
+		passphrase: passphrase,
+	}
+}
+
+// NewPassphraseEncryptorWithParams is convenience function that calls
+// NewPassphraseEncryptor. It accepts either passphrase as the only param or
+// no params at all (that's how it is restored from ShadowNet URL)
+func NewPassphraseEncryptorWithParams(logger logger.Logger, params ...[]byte) (Transformer, error) {
+	switch len(params) {
+	case 0:
+		return NewPassphraseEncryptor(logger, nil), nil
+	case 1:
+		if len(params[0]) == 0 {
+			return nil, errors.New("passphrase should not be empty")
+		}
+		return NewPassphraseEncryptor(logger, params[0]), nil
+	default:
+		return nil, errors.New("there should be at most 1 parameter: passphrase")
+	}
+}
+
+// Name returns component name of passphrase encryptor. It is always
+// PassphraseEncryptorName
+func (pe *passphraseEncryptor) Name() string {
+	return PassphraseEncryptorName
+}
+
+// Schema returns schema of passphrase encryptor. It is always PassphraseEncryptorSchema
+func (pe *passphraseEncryptor) Schema() common.ComponentSchema {
+	return PassphraseEncryptorSchema
+}
+
+// Params returns nothing, passphrase should never get into ShadowNet URL
+func (pe *passphraseEncryptor) Params() [][]byte {
+	return nil
+}
+
+// HasPassphrase reports whether passphrase was already provided
+func (pe *passphraseEncryptor) HasPassphrase() bool {
+	return len(pe.passphrase) != 0
+}
+
+// SetPassphrase provides passphrase to be used for encryption and decryption
+func (pe *passphraseEncryptor) SetPassphrase(passphrase []byte) {
+	pe.passphrase = passphrase
+}
+
+// ForwardTransform returns header with key derivation parameters followed by
+// nonce and data encrypted with AES-GCM
+func (pe *passphraseEncryptor) ForwardTransform(data []byte) ([]byte, error) {
+	if !pe.HasPassphrase() {
+		return nil, errors.New("passphrase is not provided")
+	}
+
+	header := make([]byte, passphraseHeaderLength)
+	header[0] = PassphraseFormatVersion
+	header[1] = PassphraseScryptLogN
+	header[2] = PassphraseScryptR
+	header[3] = PassphraseScryptP
+	_, err := rand.Read(header[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := pe.aead(header)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := append(header, nonce...)
+	return aead.Seal(encrypted, nonce, data, header), nil
+}
+
+// ReverseTransform returns decrypted data. IntegrityError is returned if
+// passphrase is wrong or data was modified after encryption
+func (pe *passphraseEncryptor) ReverseTransform(data []byte) ([]byte, error) {
+	if !pe.HasPassphrase() {
+		return nil, errors.New("passphrase is not provided")
+	}
+
+	if len(data) < passphraseHeaderLength {
+		return nil, &IntegrityError{
+			Component: PassphraseEncryptorName,
+			Err:       errors.New(fmt.Sprintf("data is too short: %d bytes", len(data))),
+		}
+	}
+	header := data[:passphraseHeaderLength]
+	if header[0] != PassphraseFormatVersion {
+		return nil, errors.New(fmt.Sprintf("unsupported passphrase encryption format version: %d", header[0]))
+	}
+
+	aead, err := pe.aead(header)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[passphraseHeaderLength:]
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, &IntegrityError{
+			Component: PassphraseEncryptorName,
+			Err:       errors.New(fmt.Sprintf("data is too short: %d bytes", len(data))),
+		}
+	}
+
+	decrypted, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], header)
+	if err != nil {
+		return nil, &IntegrityError{
+			Component: PassphraseEncryptorName,
+			Err:       errors.New("wrong passphrase or corrupted data"),
+		}
+	}
+
+	return decrypted, nil
+}
+
+// aead derives key from passphrase with scrypt parameters and salt from
+// header and returns AES-GCM cipher for it
+func (pe *passphraseEncryptor) aead(header []byte) (cipher.AEAD, error) {
+	logN, r, p := int(header[1]), int(header[2]), int(header[3])
+	if logN > maxPassphraseScryptLogN || r != PassphraseScryptR || p != PassphraseScryptP {
+		return nil, errors.New(fmt.Sprintf("unsupported key derivation parameters: logN=%d, r=%d, p=%d", logN, r, p))
+	}
+
+	key, err := scryptKey(pe.passphrase, header[4:], 1<<logN, r, p, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
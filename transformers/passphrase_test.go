@@ -0,0 +1,74 @@
+package transformers
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/takahawk/shadownet/logger"
+)
+
+func TestPassphraseRoundTrip(t *testing.T) {
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	data := []byte("secret content")
+	encrypted, err := NewPassphraseEncryptor(log, []byte("passphrase")).ForwardTransform(data)
+	if err != nil {
+		t.Fatalf("encryption failed: %+v", err)
+	}
+
+	decrypted, err := NewPassphraseEncryptor(log, []byte("passphrase")).ReverseTransform(encrypted)
+	if err != nil {
+		t.Fatalf("decryption failed: %+v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("decrypted data differs: %q", decrypted)
+	}
+
+	_, err = NewPassphraseEncryptor(log, []byte("wrong")).ReverseTransform(encrypted)
+	var integrityErr *IntegrityError
+	if !errors.As(err, &integrityErr) {
+		t.Fatalf("wrong passphrase should give integrity error, got %+v", err)
+	}
+}
+
+func TestPassphraseExpensiveParametersRejected(t *testing.T) {
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	encrypted, err := NewPassphraseEncryptor(log, []byte("passphrase")).ForwardTransform([]byte("data"))
+	if err != nil {
+		t.Fatalf("encryption failed: %+v", err)
+	}
+
+	derived := false
+	deriveKey := scryptKey
+	t.Cleanup(func() { scryptKey = deriveKey })
+	scryptKey = func(password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+		derived = true
+		return nil, errors.New("key should not be derived")
+	}
+
+	tests := []struct {
+		name string
+		logN byte
+		r    byte
+		p    byte
+	}{
+		{"logN", maxPassphraseScryptLogN + 1, PassphraseScryptR, PassphraseScryptP},
+		{"max logN", 255, PassphraseScryptR, PassphraseScryptP},
+		{"r", PassphraseScryptLogN, 16, PassphraseScryptP},
+		{"small r", PassphraseScryptLogN, 1, PassphraseScryptP},
+		{"p", PassphraseScryptLogN, PassphraseScryptR, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			crafted := append([]byte{}, encrypted...)
+			crafted[1], crafted[2], crafted[3] = test.logN, test.r, test.p
+			_, err := NewPassphraseEncryptor(log, []byte("passphrase")).ReverseTransform(crafted)
+			if err == nil {
+				t.Fatal("crafted parameters should be rejected")
+			}
+			if derived {
+				t.Fatal("key was derived before parameters were checked")
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package transformers
+
+import (
+	"bytes"
+	"io"
+)
+
+type bufferedStreamTransformer struct {
+	Transformer
+}
+
+type bufferedForwardWriter struct {
+	transformer Transformer
+	buf         bytes.Buffer
+	w           io.Writer
+}
+
+// NewStreamTransformer returns StreamTransformer for a given transformer. If
+// transformer doesn't support streaming by itself, the returned one collects
+// the whole data in memory and calls ForwardTransform/ReverseTransform on it
+func NewStreamTransformer(transformer Transformer) StreamTransformer {
+	if st, ok := transformer.(StreamTransformer); ok {
+		return st
+	}
+	return &bufferedStreamTransformer{
+		Transformer: transformer,
+	}
+}
+
+// ForwardTransformWriter returns writer that buffers all the data and
+// transforms it on Close
+func (bst *bufferedStreamTransformer) ForwardTransformWriter(w io.Writer) (io.WriteCloser, error) {
+	return &bufferedForwardWriter{
+		transformer: bst.Transformer,
+		w:           w,
+	}, nil
+}
+
+// ReverseTransformReader reads r until EOF and returns reader over the
+// reverse transformed data
+func (bst *bufferedStreamTransformer) ReverseTransformReader(r io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err = bst.ReverseTransform(data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (bfw *bufferedForwardWriter) Write(p []byte) (int, error) {
+	return bfw.buf.Write(p)
+}
+
+func (bfw *bufferedForwardWriter) Close() error {
+	data, err := bfw.transformer.ForwardTransform(bfw.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = bfw.w.Write(data)
+	return err
+}
@@ -1,6 +1,8 @@
 package transformers
 
 import (
+	"io"
+
 	"github.com/takahawk/shadownet/common"
 )
 
@@ -16,3 +18,16 @@ type Transformer interface {
 	// original one
 	ReverseTransform(data []byte) ([]byte, error)
 }
+
+// StreamTransformer is Transformer that is able to process data as a stream
+// without holding the whole content in memory
+type StreamTransformer interface {
+	Transformer
+	// ForwardTransformWriter returns writer that transforms everything written
+	// to it and passes the result to w. Writer should be closed to flush the
+	// remaining data. Closing it doesn't close w
+	ForwardTransformWriter(w io.Writer) (io.WriteCloser, error)
+	// ReverseTransformReader returns reader that gives the original version
+	// of data read from r
+	ReverseTransformReader(r io.Reader) (io.Reader, error)
+}
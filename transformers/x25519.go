@@ -0,0 +1,247 @@
+package transformers
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/logger"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// X25519EncryptorName is component name for X25519 recipients encryptor
+const X25519EncryptorName = "x25519"
+
+// X25519EncryptorSchema describes X25519 encryptor and its params
+var X25519EncryptorSchema = common.ComponentSchema{
+	Name:        X25519EncryptorName,
+	Description: "Encrypts data for recipients with X25519 public keys",
+	Params: []common.ParamSchema{
+		{Name: "recipient", Type: common.ParamTypeBytes, Description: "public key of recipient", Lengths: []int{32}, Variadic: true},
+	},
+}
+
+// X25519FormatVersion is version of encrypted data layout written by X25519
+// encryptor
+const X25519FormatVersion = 1
+
+// x25519WrapInfo is HKDF info used to derive key wrapping file key for
+// a single recipient
+const x25519WrapInfo = "shadownet/x25519/wrap"
+
+// fileKeyLength is length of random key used to encrypt the payload
+const fileKeyLength = chacha20poly1305.KeySize
+
+// every recipient stanza is ephemeral public key and wrapped file key
+const x25519StanzaLength = 32 + fileKeyLength + chacha20poly1305.Overhead
+
+type x25519Encryptor struct {
+	logger     logger.Logger
+	recipients [][]byte
+	identity   *ecdh.PrivateKey
+	// index of recipient that identity belongs to
+	identityIndex int
+}
+
+// NewX25519Encryptor creates new transformer that encrypts data so that it
+// can be decrypted only by holders of private keys for given X25519 public
+// keys. Random file key encrypts the payload and it is wrapped for every
+// recipient separately using ephemeral key agreement (similar to age).
+// Decryption requires private key of one of recipients to be set with
+// SetPrivateKey
+func NewX25519Encryptor(logger logger.Logger, recipients ...[]byte) (KeyHolder, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("there should be at least one recipient")
+	}
+	if len(recipients) > 255 {
+		return nil, errors.New("there can be at most 255 recipients")
+	}
+	for _, recipient := range recipients {
+		_, err := ecdh.X25519().NewPublicKey(recipient)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid recipient public key: %+v", err))
+		}
+	}
+	return &x25519Encryptor{
+		logger:        logger,
+		recipients:    recipients,
+		identityIndex: -1,
+	}, nil
+}
+
+// NewX25519EncryptorWithParams is convenience function that calls
+// NewX25519Encryptor with recipients' public keys as params
+func NewX25519EncryptorWithParams(logger logger.Logger, params ...[]byte) (Transformer, error) {
+	return NewX25519Encryptor(logger, params...)
+}
+
+// Name returns component name of X25519 encryptor. It is always
+// X25519EncryptorName
+func (xe *x25519Encryptor) Name() string {
+	return X25519EncryptorName
+}
+
+// Schema returns schema of X25519 encryptor. It is always X25519EncryptorSchema
+func (xe *x25519Encryptor) Schema() common.ComponentSchema {
+	return X25519EncryptorSchema
+}
+
+// Params returns public keys of recipients
+func (xe *x25519Encryptor) Params() [][]byte {
+	return xe.recipients
+}
+
+// PublicKeys returns public keys of recipients
+func (xe *x25519Encryptor) PublicKeys() [][]byte {
+	return xe.recipients
+}
+
+// SetPrivateKey provides private key of one of recipients which is used for
+// decryption
+func (xe *x25519Encryptor) SetPrivateKey(publicKey []byte, privateKey []byte) error {
+	identity, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(identity.PublicKey().Bytes(), publicKey) {
+		return errors.New("private key doesn't match public key")
+	}
+	for i, recipient := range xe.recipients {
+		if bytes.Equal(recipient, publicKey) {
+			xe.identity = identity
+			xe.identityIndex = i
+			return nil
+		}
+	}
+	return errors.New("public key is not among recipients")
+}
+
+// ForwardTransform returns header with file key wrapped for every recipient
+// followed by nonce and data encrypted with file key
+func (xe *x25519Encryptor) ForwardTransform(data []byte) ([]byte, error) {
+	fileKey := make([]byte, fileKeyLength)
+	_, err := rand.Read(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte{X25519FormatVersion, byte(len(xe.recipients))}
+	for _, recipient := range xe.recipients {
+		recipientKey, err := ecdh.X25519().NewPublicKey(recipient)
+		if err != nil {
+			return nil, err
+		}
+		ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := ephemeral.ECDH(recipientKey)
+		if err != nil {
+			return nil, err
+		}
+		wrapping, err := x25519WrappingAEAD(shared, ephemeral.PublicKey().Bytes(), recipient)
+		if err != nil {
+			return nil, err
+		}
+
+		header = append(header, ephemeral.PublicKey().Bytes()...)
+		// wrapping key is unique for every ephemeral key, so zero nonce is fine
+		header = wrapping.Seal(header, make([]byte, wrapping.NonceSize()), fileKey, nil)
+	}
+
+	aead, err := chacha20poly1305.New(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := append(header, nonce...)
+	return aead.Seal(encrypted, nonce, data, header), nil
+}
+
+// ReverseTransform unwraps file key with private key of recipient and
+// returns decrypted data. IntegrityError is returned if data was modified
+func (xe *x25519Encryptor) ReverseTransform(data []byte) ([]byte, error) {
+	if xe.identity == nil {
+		return nil, errors.New("there is no private key for any of recipients")
+	}
+
+	if len(data) < 2 {
+		return nil, &IntegrityError{
+			Component: X25519EncryptorName,
+			Err:       errors.New(fmt.Sprintf("data is too short: %d bytes", len(data))),
+		}
+	}
+	if data[0] != X25519FormatVersion {
+		return nil, errors.New(fmt.Sprintf("unsupported x25519 encryption format version: %d", data[0]))
+	}
+	count := int(data[1])
+	if count != len(xe.recipients) {
+		return nil, &IntegrityError{
+			Component: X25519EncryptorName,
+			Err:       errors.New("number of recipients doesn't match"),
+		}
+	}
+	headerLength := 2 + count*x25519StanzaLength
+	if len(data) < headerLength+chacha20poly1305.NonceSize+chacha20poly1305.Overhead {
+		return nil, &IntegrityError{
+			Component: X25519EncryptorName,
+			Err:       errors.New(fmt.Sprintf("data is too short: %d bytes", len(data))),
+		}
+	}
+
+	stanza := data[2+xe.identityIndex*x25519StanzaLength : 2+(xe.identityIndex+1)*x25519StanzaLength]
+	ephemeral, err := ecdh.X25519().NewPublicKey(stanza[:32])
+	if err != nil {
+		return nil, &IntegrityError{Component: X25519EncryptorName, Err: err}
+	}
+	shared, err := xe.identity.ECDH(ephemeral)
+	if err != nil {
+		return nil, &IntegrityError{Component: X25519EncryptorName, Err: err}
+	}
+	wrapping, err := x25519WrappingAEAD(shared, stanza[:32], xe.recipients[xe.identityIndex])
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := wrapping.Open(nil, make([]byte, wrapping.NonceSize()), stanza[32:], nil)
+	if err != nil {
+		return nil, &IntegrityError{Component: X25519EncryptorName, Err: err}
+	}
+
+	aead, err := chacha20poly1305.New(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	header := data[:headerLength]
+	nonce := data[headerLength : headerLength+aead.NonceSize()]
+	decrypted, err := aead.Open(nil, nonce, data[headerLength+aead.NonceSize():], header)
+	if err != nil {
+		return nil, &IntegrityError{Component: X25519EncryptorName, Err: err}
+	}
+
+	return decrypted, nil
+}
+
+// x25519WrappingAEAD returns cipher used to wrap file key for a recipient.
+// Its key is derived from shared secret bound to both ephemeral and
+// recipient public keys
+func x25519WrappingAEAD(shared []byte, ephemeral []byte, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519WrapInfo)), key)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(key)
+}
@@ -0,0 +1,50 @@
+package uploaders
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/takahawk/shadownet/oauth"
+)
+
+// AccountParamPrefix is prefix of uploader param that refers to OAuth account
+// linked to gateway instead of containing access token itself
+const AccountParamPrefix = "account:"
+
+// AccountUploader is Uploader that is able to use OAuth account linked to
+// gateway. Provider of account access tokens is set by gateway
+type AccountUploader interface {
+	Uploader
+	// Account returns name of account to be used or empty string if access
+	// token is given directly
+	Account() string
+	// SetTokenProvider sets provider of account access tokens
+	SetTokenProvider(tokens oauth.TokenProvider)
+}
+
+// parseTokenParam returns either access token or name of account given by
+// uploader param
+func parseTokenParam(param []byte) (token string, account string) {
+	if strings.HasPrefix(string(param), AccountParamPrefix) {
+		return "", strings.TrimPrefix(string(param), AccountParamPrefix)
+	}
+	return string(param), ""
+}
+
+// tokenParam is the reverse of parseTokenParam
+func tokenParam(token string, account string) []byte {
+	if account != "" {
+		return []byte(AccountParamPrefix + account)
+	}
+	return []byte(token)
+}
+
+// accessToken returns token from provider or error if there is no provider
+// set for account yet
+func accessToken(tokens oauth.TokenProvider, account string) (string, error) {
+	if tokens == nil {
+		return "", errors.New(fmt.Sprintf("there is no token provider for account \"%s\"", account))
+	}
+	return tokens.Token()
+}
@@ -0,0 +1,160 @@
+package uploaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// ChunkedUploaderName is chunked uploader component name
+const ChunkedUploaderName = "chunked"
+
+// ChunkedUploaderSchema describes chunked uploader and its params
+var ChunkedUploaderSchema = common.ComponentSchema{
+	Name:        ChunkedUploaderName,
+	Description: "Splits data into chunks uploaded with the following uploaders",
+	Params: []common.ParamSchema{
+		{Name: "chunkSize", Type: common.ParamTypeInt, Description: "size of chunk in bytes", Optional: true, Default: strconv.Itoa(DefaultChunkSize)},
+	},
+}
+
+// DefaultChunkSize is size of chunks used when it isn't set explicitly
+const DefaultChunkSize = 256 * 1024
+
+type chunkedUploader struct {
+	logger    logger.Logger
+	chunkSize int
+	uploader  Uploader
+}
+
+// NewChunkedUploader returns uploader that splits data into chunks of a
+// given size and uploads every chunk separately. Afterwards manifest listing
+// all chunks with their hashes is uploaded and its id is returned. Uploader
+// used for chunks and manifest should be added with AddUploaders
+func NewChunkedUploader(logger logger.Logger, chunkSize int) (CompositeUploader, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("chunk size should be positive")
+	}
+	return &chunkedUploader{
+		logger:    logger,
+		chunkSize: chunkSize,
+	}, nil
+}
+
+// NewChunkedUploaderWithParams is convenience function that calls
+// NewChunkedUploader. Chunk size in decimal form is the only optional param
+func NewChunkedUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	switch len(params) {
+	case 0:
+		return NewChunkedUploader(logger, DefaultChunkSize)
+	case 1:
+		chunkSize, err := strconv.Atoi(string(params[0]))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid chunk size: %s", params[0]))
+		}
+		return NewChunkedUploader(logger, chunkSize)
+	default:
+		return nil, errors.New("there should be at most 1 parameter: chunk size")
+	}
+}
+
+// Name returns chunked uploader component name. It is always
+// ChunkedUploaderName
+func (cu *chunkedUploader) Name() string {
+	return ChunkedUploaderName
+}
+
+// Schema returns schema of chunked uploader. It is always ChunkedUploaderSchema
+func (cu *chunkedUploader) Schema() common.ComponentSchema {
+	return ChunkedUploaderSchema
+}
+
+// Params returns chunk size in decimal form
+func (cu *chunkedUploader) Params() [][]byte {
+	return [][]byte{[]byte(strconv.Itoa(cu.chunkSize))}
+}
+
+// AddUploaders sets uploader used for chunks and manifest. Exactly one
+// uploader is expected
+func (cu *chunkedUploader) AddUploaders(uploaders ...Uploader) error {
+	if cu.uploader != nil || len(uploaders) != 1 {
+		return errors.New("chunked uploader needs exactly one uploader")
+	}
+	cu.uploader = uploaders[0]
+	return nil
+}
+
+// DownloaderFor returns chunked downloader with downloader of manifest as
+// the only param. Nothing is returned if there is no downloader for manifest
+func (cu *chunkedUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	manifestName, manifestParams := cu.uploader.DownloaderFor(id)
+	if manifestName == "" {
+		return "", nil
+	}
+	return downloaders.ChunkedDownloaderName, [][]byte{common.EncodeComponentSpec(manifestName, manifestParams)}
+}
+
+// Upload splits data into chunks and uploads them along with manifest
+func (cu *chunkedUploader) Upload(content []byte) (id string, err error) {
+	return cu.UploadStream(bytes.NewReader(content))
+}
+
+// UploadStream reads content chunk by chunk uploading every one of them as
+// soon as it is read. Manifest is uploaded at the end
+func (cu *chunkedUploader) UploadStream(content io.Reader) (id string, err error) {
+	if cu.uploader == nil {
+		return "", errors.New("there is no uploader for chunks")
+	}
+
+	manifest := downloaders.ChunkManifest{
+		Version: downloaders.ChunkManifestVersion,
+	}
+	chunk := make([]byte, cu.chunkSize)
+	for {
+		n, err := io.ReadFull(content, chunk)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return "", err
+		}
+
+		cu.logger.Infof("Uploading chunk %d (%d bytes)", len(manifest.Chunks), n)
+		chunkID, err := cu.uploader.Upload(chunk[:n])
+		if err != nil {
+			cu.logger.Errorf("Error uploading chunk %d: %+v", len(manifest.Chunks), err)
+			return "", err
+		}
+		hash := sha256.Sum256(chunk[:n])
+		name, params := cu.uploader.DownloaderFor(chunkID)
+		if !downloaders.IsChunkDownloader(name) {
+			err = errors.New(fmt.Sprintf("chunks can't be downloaded with %s, it should be one of %v", name, downloaders.ChunkDownloaderNames))
+			cu.logger.Errorf("%+v", err)
+			return "", err
+		}
+		manifest.Chunks = append(manifest.Chunks, downloaders.ChunkManifestEntry{
+			Downloader: name,
+			Params:     params,
+			Size:       int64(n),
+			SHA256:     hex.EncodeToString(hash[:]),
+		})
+		manifest.Size += int64(n)
+	}
+
+	manifestJson, err := json.Marshal(manifest)
+	if err != nil {
+		cu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	cu.logger.Infof("Uploading manifest of %d chunks", len(manifest.Chunks))
+	return cu.uploader.Upload(manifestJson)
+}
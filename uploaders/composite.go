@@ -0,0 +1,70 @@
+package uploaders
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/takahawk/shadownet/common"
+)
+
+// CompositeUploader is Uploader that doesn't store data by itself, but
+// spreads it among other uploaders. In pipeline specification it is
+// followed by uploaders it should use
+type CompositeUploader interface {
+	Uploader
+	// AddUploaders adds uploaders which data is spread among
+	AddUploaders(uploaders ...Uploader) error
+}
+
+// WarningUploader is Uploader that tolerates failures of some of its parts
+// (i.e. mirror uploader with minimum number of mirrors). Such failures are
+// reported as warnings instead of errors
+type WarningUploader interface {
+	Uploader
+	// Warnings returns failures tolerated during uploads made so far
+	Warnings() []string
+}
+
+// collectWarnings returns warnings of uploaders that report them
+func collectWarnings(uploaders ...Uploader) []string {
+	var warnings []string
+	for _, uploader := range uploaders {
+		if warner, ok := uploader.(WarningUploader); ok {
+			warnings = append(warnings, warner.Warnings()...)
+		}
+	}
+	return warnings
+}
+
+// downloaderSpec returns encoded spec of downloader for data uploaded with
+// uploader. Uploader without downloader (i.e. plugin that failed to give it)
+// is reported as error, since such data can't be downloaded
+func downloaderSpec(uploader Uploader, id string) ([]byte, error) {
+	name, params := uploader.DownloaderFor(id)
+	if name == "" {
+		return nil, errors.New(fmt.Sprintf("uploader %s hasn't provided downloader for uploaded data", uploader.Name()))
+	}
+	return common.EncodeComponentSpec(name, params), nil
+}
+
+// packDownloaderParams returns id that holds params of downloader. It is used
+// by composite uploaders which downloader params can't be derived from
+// anything else
+func packDownloaderParams(name string, params [][]byte) string {
+	return base64.RawURLEncoding.EncodeToString(common.EncodeComponentSpec(name, params))
+}
+
+// unpackDownloaderParams returns params of downloader packed into id with
+// packDownloaderParams
+func unpackDownloaderParams(id string) ([][]byte, error) {
+	spec, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return nil, errors.New("invalid id of composite upload")
+	}
+	_, params, err := common.DecodeComponentSpec(spec)
+	if err != nil {
+		return nil, errors.New("invalid id of composite upload")
+	}
+	return params, nil
+}
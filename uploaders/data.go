@@ -0,0 +1,131 @@
+package uploaders
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// DataUploaderName is data uploader component name
+const DataUploaderName = "data"
+
+// DataUploaderSchema describes data uploader and its params
+var DataUploaderSchema = common.ComponentSchema{
+	Name:        DataUploaderName,
+	Description: "Puts data into ShadowNet URL itself",
+	Params: []common.ParamSchema{
+		{Name: "maxUrlLength", Type: common.ParamTypeInt, Description: "maximum length of ShadowNet URL", Optional: true, Default: strconv.Itoa(DefaultMaxURLLength)},
+	},
+}
+
+// DefaultMaxURLLength is maximum length of ShadowNet URL that is supported
+// by all browsers (see facts.md)
+const DefaultMaxURLLength = 2048
+
+// URLLimitedUploader is Uploader that keeps content in ShadowNet URL itself,
+// so that length of the resulting URL should be limited
+type URLLimitedUploader interface {
+	Uploader
+	// MaxURLLength returns maximum allowed length of ShadowNet URL
+	MaxURLLength() int
+}
+
+type dataUploader struct {
+	logger       logger.Logger
+	maxURLLength int
+}
+
+// NewDataUploader returns uploader that doesn't upload anything, but uses
+// content itself as id, so that it is embedded into ShadowNet URL. It is
+// meant for tiny payloads only: content that doesn't fit into URL of a
+// given maximum length is refused
+func NewDataUploader(logger logger.Logger, maxURLLength int) URLLimitedUploader {
+	return &dataUploader{
+		logger:       logger,
+		maxURLLength: maxURLLength,
+	}
+}
+
+// NewDataUploaderWithParams is convenience function that calls
+// NewDataUploader. It does expect optional decimal maximum URL length
+// (DefaultMaxURLLength by default)
+func NewDataUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	switch len(params) {
+	case 0:
+		return NewDataUploader(logger, DefaultMaxURLLength), nil
+	case 1:
+		maxURLLength, err := strconv.Atoi(string(params[0]))
+		if err != nil || maxURLLength <= 0 {
+			return nil, errors.New(fmt.Sprintf("invalid maximum URL length: %s", string(params[0])))
+		}
+		return NewDataUploader(logger, maxURLLength), nil
+	default:
+		return nil, errors.New("there should be at most 1 param: maximum URL length")
+	}
+}
+
+// Name returns data uploader name. It is always DataUploaderName
+func (du *dataUploader) Name() string {
+	return DataUploaderName
+}
+
+// Schema returns schema of data uploader. It is always DataUploaderSchema
+func (du *dataUploader) Schema() common.ComponentSchema {
+	return DataUploaderSchema
+}
+
+// Params returns maximum URL length packed into byte array
+func (du *dataUploader) Params() [][]byte {
+	return [][]byte{[]byte(strconv.Itoa(du.maxURLLength))}
+}
+
+// MaxURLLength returns maximum allowed length of ShadowNet URL
+func (du *dataUploader) MaxURLLength() int {
+	return du.maxURLLength
+}
+
+// DownloaderFor returns data downloader with content as the only param
+func (du *dataUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.DataDownloaderName, [][]byte{[]byte(id)}
+}
+
+// Upload returns content itself as id. Content that can't fit into URL of
+// maximum length even compressed is refused. Exact length of the resulting
+// URL is checked by upload pipeline, since it depends on other components
+func (du *dataUploader) Upload(content []byte) (id string, err error) {
+	if !fitsURL(content, du.maxURLLength) {
+		err = errors.New(fmt.Sprintf("%d bytes of data can't fit into ShadowNet URL of %d characters", len(content), du.maxURLLength))
+		du.logger.Errorf("%+v", err)
+		return "", err
+	}
+	return string(content), nil
+}
+
+// maxDeflateRatio is maximum compression ratio of DEFLATE
+const maxDeflateRatio = 1032
+
+// fitsURL tells whether content can fit into URL of a given length. Content
+// is put into base64 encoded URL body that may be compressed with DEFLATE, so
+// it should fit at least in compressed form
+func fitsURL(content []byte, maxURLLength int) bool {
+	limit := base64.RawURLEncoding.DecodedLen(maxURLLength)
+	if len(content) <= limit {
+		return true
+	}
+	// huge content is refused without compressing it
+	if len(content)/maxDeflateRatio > limit {
+		return false
+	}
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	fw.Write(content)
+	fw.Close()
+	return compressed.Len() <= limit
+}
@@ -10,12 +10,24 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
 	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/oauth"
 )
 
 // DropboxUploaderName is dropbox uploader component name
 const DropboxUploaderName = "dropbox"
 
+// DropboxUploaderSchema describes Dropbox uploader and its params
+var DropboxUploaderSchema = common.ComponentSchema{
+	Name:        DropboxUploaderName,
+	Description: "Uploads file to Dropbox and shares it",
+	Params: []common.ParamSchema{
+		{Name: "token", Type: common.ParamTypeString, Description: "access token or \"account:<name>\" of linked OAuth account", Secret: true},
+	},
+}
+
 // DropboxApiUrlUpload is URL to send POST upload requests to dropbox
 const DropboxApiUrlUpload = "https://content.dropboxapi.com/2/files/upload"
 
@@ -70,7 +82,10 @@ const (
 const RandomFilenameBytes = 16
 
 type dropboxUploader struct {
+	// accessToken is only set if it is given directly instead of account
 	accessToken string
+	account     string
+	tokens      oauth.TokenProvider
 	logger      logger.Logger
 }
 
@@ -100,17 +115,32 @@ type dropboxCreateSharedLinkResponseBody struct {
 func NewDropboxUploader(logger logger.Logger, accessToken string) Uploader {
 	return &dropboxUploader{
 		accessToken: accessToken,
+		tokens:      oauth.NewStaticTokenProvider(accessToken),
 		logger:      logger,
 	}
 }
 
+// NewDropboxAccountUploader returns new dropbox uploader that will be using
+// OAuth account linked to gateway with a given name
+func NewDropboxAccountUploader(logger logger.Logger, account string) AccountUploader {
+	return &dropboxUploader{
+		account: account,
+		logger:  logger,
+	}
+}
+
 // NewDropboxUploaderWithParams is convinience method that calls NewDropboxUploader
-// but with access token packed into byte slice
+// but with access token packed into byte slice. Param can also be name of
+// linked account prefixed with AccountParamPrefix
 func NewDropboxUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
 	if len(params) != 1 {
-		return nil, errors.New("there should be exactly 1 parameter: Dropbox access token")
+		return nil, errors.New("there should be exactly 1 parameter: Dropbox access token or account")
+	}
+	token, account := parseTokenParam(params[0])
+	if account != "" {
+		return NewDropboxAccountUploader(logger, account), nil
 	}
-	return NewDropboxUploader(logger, string(params[0])), nil
+	return NewDropboxUploader(logger, token), nil
 }
 
 // Name returns Dropbox uploader component name. It is always DropboxUploaderName
@@ -118,21 +148,52 @@ func (du *dropboxUploader) Name() string {
 	return DropboxUploaderName
 }
 
-// Params returns access token packed into byte slice
+// Schema returns schema of Dropbox uploader. It is always DropboxUploaderSchema
+func (du *dropboxUploader) Schema() common.ComponentSchema {
+	return DropboxUploaderSchema
+}
+
+// Params returns access token or account packed into byte slice
 func (du *dropboxUploader) Params() [][]byte {
-	return [][]byte{[]byte(du.accessToken)}
+	return [][]byte{tokenParam(du.accessToken, du.account)}
+}
+
+// Account returns name of linked account if it is used
+func (du *dropboxUploader) Account() string {
+	return du.account
+}
+
+// SetTokenProvider sets provider of linked account access tokens
+func (du *dropboxUploader) SetTokenProvider(tokens oauth.TokenProvider) {
+	du.tokens = tokens
+}
+
+// DownloaderFor returns dropbox downloader with shared link as the only param
+func (du *dropboxUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.DropboxDownloaderName, [][]byte{[]byte(id)}
 }
 
 // Upload uploads given file to dropbox and returns shared link to it
 func (du *dropboxUploader) Upload(data []byte) (id string, err error) {
+	return du.UploadStream(bytes.NewReader(data))
+}
+
+// UploadStream uploads content to dropbox as it is being read and returns
+// shared link to it
+func (du *dropboxUploader) UploadStream(content io.Reader) (id string, err error) {
 	du.logger.Info("Uploading data to Dropbox...")
+	token, err := accessToken(du.tokens, du.account)
+	if err != nil {
+		du.logger.Errorf("%+v", err)
+		return "", err
+	}
 	client := &http.Client{}
-	r, err := http.NewRequest(http.MethodPost, DropboxApiUrlUpload, bytes.NewReader(data))
+	r, err := http.NewRequest(http.MethodPost, DropboxApiUrlUpload, content)
 	if err != nil {
 		du.logger.Errorf("%+v", err)
 		return "", err
 	}
-	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", du.accessToken))
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
 	id = generateRandomFilename()
 	args := dropboxUploadApiArg{
@@ -187,7 +248,13 @@ func (du *dropboxUploader) Upload(data []byte) (id string, err error) {
 		du.logger.Errorf("%+v", err)
 		return "", err
 	}
-	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", du.accessToken))
+	// upload may take long enough for token to expire
+	token, err = accessToken(du.tokens, du.account)
+	if err != nil {
+		du.logger.Errorf("%+v", err)
+		return "", err
+	}
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	r.Header.Set("Content-Type", "application/json")
 
 	rsp, err = client.Do(r)
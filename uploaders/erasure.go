@@ -0,0 +1,164 @@
+package uploaders
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// ErasureUploaderName is erasure uploader component name
+const ErasureUploaderName = "erasure"
+
+// ErasureUploaderSchema describes erasure uploader and its params
+var ErasureUploaderSchema = common.ComponentSchema{
+	Name:        ErasureUploaderName,
+	Description: "Spreads erasure coded shards among the following uploaders",
+	Params: []common.ParamSchema{
+		{Name: "dataShards", Type: common.ParamTypeInt, Description: "number of data shards"},
+	},
+}
+
+type erasureUploader struct {
+	logger     logger.Logger
+	dataShards int
+	uploaders  []Uploader
+}
+
+// NewErasureUploader returns uploader that encodes data into Reed-Solomon
+// shards, one for every uploader added with AddUploaders, and uploads every
+// shard with its own uploader. Any dataShards of shards are enough to rebuild
+// the data, so there should be more uploaders than that
+func NewErasureUploader(logger logger.Logger, dataShards int) (CompositeUploader, error) {
+	if dataShards <= 0 {
+		return nil, errors.New("number of data shards should be positive")
+	}
+	return &erasureUploader{
+		logger:     logger,
+		dataShards: dataShards,
+	}, nil
+}
+
+// NewErasureUploaderWithParams is convenience function that calls
+// NewErasureUploader. Number of data shards in decimal form is the only param
+func NewErasureUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be exactly 1 parameter: number of data shards")
+	}
+	dataShards, err := strconv.Atoi(string(params[0]))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid number of data shards: %s", params[0]))
+	}
+	return NewErasureUploader(logger, dataShards)
+}
+
+// Name returns erasure uploader component name. It is always
+// ErasureUploaderName
+func (eu *erasureUploader) Name() string {
+	return ErasureUploaderName
+}
+
+// Schema returns schema of erasure uploader. It is always ErasureUploaderSchema
+func (eu *erasureUploader) Schema() common.ComponentSchema {
+	return ErasureUploaderSchema
+}
+
+// Params returns number of data shards in decimal form
+func (eu *erasureUploader) Params() [][]byte {
+	return [][]byte{[]byte(strconv.Itoa(eu.dataShards))}
+}
+
+// AddUploaders adds uploaders for shards. Every uploader gets one shard
+func (eu *erasureUploader) AddUploaders(uploaders ...Uploader) error {
+	if len(eu.uploaders)+len(uploaders) > 256 {
+		return errors.New("there can be at most 256 shards")
+	}
+	eu.uploaders = append(eu.uploaders, uploaders...)
+	return nil
+}
+
+// DownloaderFor returns erasure downloader with params packed into id
+func (eu *erasureUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	params, err := unpackDownloaderParams(id)
+	if err != nil {
+		eu.logger.Errorf("%+v", err)
+	}
+	return downloaders.ErasureDownloaderName, params
+}
+
+// Warnings returns warnings of uploaders of shards
+func (eu *erasureUploader) Warnings() []string {
+	return collectWarnings(eu.uploaders...)
+}
+
+// Upload encodes data into shards and uploads all of them in parallel. Id
+// returned describes location of all shards
+func (eu *erasureUploader) Upload(content []byte) (id string, err error) {
+	if len(eu.uploaders) <= eu.dataShards {
+		return "", errors.New(fmt.Sprintf("there should be more than %d uploaders for shards", eu.dataShards))
+	}
+
+	enc, err := reedsolomon.New(eu.dataShards, len(eu.uploaders)-eu.dataShards)
+	if err != nil {
+		return "", err
+	}
+	// Split doesn't accept empty data, so single zero byte is encoded instead.
+	// It is dropped on download anyway since original size is known
+	data := content
+	if len(data) == 0 {
+		data = []byte{0}
+	}
+	shards, err := enc.Split(data)
+	if err != nil {
+		return "", err
+	}
+	err = enc.Encode(shards)
+	if err != nil {
+		return "", err
+	}
+
+	params := make([][]byte, 2+len(shards))
+	params[0] = []byte(strconv.Itoa(eu.dataShards))
+	params[1] = []byte(strconv.Itoa(len(content)))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i := range shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uploader := eu.uploaders[i]
+			eu.logger.Infof("Uploading shard %d to %s", i, uploader.Name())
+			shardID, err := uploader.Upload(shards[i])
+			if err != nil {
+				eu.logger.Errorf("Error uploading shard %d: %+v", i, err)
+				errs[i] = err
+				return
+			}
+			spec, err := downloaderSpec(uploader, shardID)
+			if err != nil {
+				eu.logger.Errorf("Error uploading shard %d: %+v", i, err)
+				errs[i] = err
+				return
+			}
+			hash := sha256.Sum256(shards[i])
+			params[2+i] = append(hash[:], spec...)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// id is params of erasure downloader since they can't be derived from
+	// anything else
+	return packDownloaderParams(downloaders.ErasureDownloaderName, params), nil
+}
@@ -0,0 +1,117 @@
+package uploaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// FileUploaderName is file uploader component name
+const FileUploaderName = "file"
+
+// FileUploaderSchema describes file uploader and its params
+var FileUploaderSchema = common.ComponentSchema{
+	Name:        FileUploaderName,
+	Description: "Stores data in blob directory of gateway",
+	Params:      []common.ParamSchema{},
+}
+
+type fileUploader struct {
+	logger logger.Logger
+	dir    string
+}
+
+// NewFileUploader returns uploader that saves data into a given directory
+// on local filesystem. Data is content-addressed: its id is hex SHA-256 hash,
+// so uploading the same data twice stores it once
+func NewFileUploader(logger logger.Logger, dir string) Uploader {
+	return &fileUploader{
+		logger: logger,
+		dir:    dir,
+	}
+}
+
+// NewFileUploaderWithParams returns uploader for a given params. It
+// doesn't expect any params: directory is configuration of gateway rather
+// than part of pipeline spec
+func NewFileUploaderWithParams(logger logger.Logger, dir string, params ...[]byte) (Uploader, error) {
+	if len(params) != 0 {
+		return nil, errors.New("there should be no params")
+	}
+	return NewFileUploader(logger, dir), nil
+}
+
+// Name returns file uploader name. It is always FileUploaderName
+func (fu *fileUploader) Name() string {
+	return FileUploaderName
+}
+
+// Schema returns schema of file uploader. It is always FileUploaderSchema
+func (fu *fileUploader) Schema() common.ComponentSchema {
+	return FileUploaderSchema
+}
+
+// Params returns no params, since directory is not part of pipeline spec
+func (fu *fileUploader) Params() [][]byte {
+	return nil
+}
+
+// DownloaderFor returns file downloader with blob id as param. Directory is
+// not included, so it is not exposed by URLs
+func (fu *fileUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.FileDownloaderName, [][]byte{[]byte(id)}
+}
+
+// Upload saves data as a blob and returns its id
+func (fu *fileUploader) Upload(content []byte) (id string, err error) {
+	return fu.UploadStream(bytes.NewReader(content))
+}
+
+// UploadStream saves content into temporary file while it is being read and
+// then moves it to its place by id
+func (fu *fileUploader) UploadStream(content io.Reader) (id string, err error) {
+	err = os.MkdirAll(fu.dir, 0755)
+	if err != nil {
+		fu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	tmp, err := os.CreateTemp(fu.dir, ".upload-*")
+	if err != nil {
+		fu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fu.logger.Errorf("%+v", err)
+		return "", err
+	}
+
+	id = hex.EncodeToString(hash.Sum(nil))
+	path := downloaders.FileBlobPath(fu.dir, id)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		fu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		fu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	fu.logger.Infof("Saved blob %s", path)
+	return id, nil
+}
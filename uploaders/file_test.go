@@ -0,0 +1,100 @@
+package uploaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+func TestFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	uploader, err := NewFileUploaderWithParams(log, dir)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	content := []byte("content of blob")
+	id, err := uploader.Upload(content)
+	if err != nil {
+		t.Fatalf("upload failed: %+v", err)
+	}
+	hash := sha256.Sum256(content)
+	if id != hex.EncodeToString(hash[:]) {
+		t.Fatalf("id should be SHA-256 of content, got %s", id)
+	}
+
+	name, params := uploader.DownloaderFor(id)
+	if name != downloaders.FileDownloaderName {
+		t.Fatalf("expected %s downloader, got %s", downloaders.FileDownloaderName, name)
+	}
+	downloader, err := downloaders.NewFileDownloaderWithParams(log, dir, params...)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	downloaded, err := downloader.Download()
+	if err != nil {
+		t.Fatalf("download failed: %+v", err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Fatalf("downloaded content differs: %q", downloaded)
+	}
+	downloadedHash := sha256.Sum256(downloaded)
+	if hex.EncodeToString(downloadedHash[:]) != id {
+		t.Fatal("hash of downloaded content doesn't match id")
+	}
+
+	// the same content is stored once
+	again, err := uploader.Upload(content)
+	if err != nil || again != id {
+		t.Fatalf("expected the same id %s, got %s (%v)", id, again, err)
+	}
+}
+
+func TestFileTamperedBlob(t *testing.T) {
+	dir := t.TempDir()
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	uploader := NewFileUploader(log, dir)
+	id, err := uploader.Upload([]byte("original content"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	err = os.WriteFile(downloaders.FileBlobPath(dir, id), []byte("tampered content"), 0644)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	downloader, err := downloaders.NewFileDownloader(log, dir, id)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err = downloader.Download()
+	if err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Fatalf("tampered blob should be rejected, got %v", err)
+	}
+	// nothing is streamed before hash is checked
+	r, err := downloaders.NewStreamDownloader(downloader).DownloadStream()
+	if err == nil {
+		data, _ := io.ReadAll(r)
+		r.Close()
+		t.Fatalf("tampered blob is streamed: %q", data)
+	}
+}
+
+func TestFileInvalidID(t *testing.T) {
+	dir := t.TempDir()
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	for _, id := range []string{"../../etc/passwd", "", strings.Repeat("g", 64)} {
+		_, err := downloaders.NewFileDownloader(log, dir, id)
+		if err == nil {
+			t.Fatalf("id %q should be rejected", id)
+		}
+	}
+}
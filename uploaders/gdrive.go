@@ -0,0 +1,235 @@
+package uploaders
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+	"github.com/takahawk/shadownet/oauth"
+)
+
+// GDriveUploaderName is gdrive uploader component name
+const GDriveUploaderName = "gdrive"
+
+// GDriveUploaderSchema describes Google Drive uploader and its params
+var GDriveUploaderSchema = common.ComponentSchema{
+	Name:        GDriveUploaderName,
+	Description: "Uploads file to Google Drive and shares it by link",
+	Params: []common.ParamSchema{
+		{Name: "token", Type: common.ParamTypeString, Description: "access token or \"account:<name>\" of linked OAuth account", Secret: true},
+	},
+}
+
+// GDriveApiUrlUpload is URL to send POST multipart upload requests to Google
+// Drive
+const GDriveApiUrlUpload = "https://www.googleapis.com/upload/drive/v3/files?uploadType=multipart&fields=id"
+
+// GDriveApiUrlFiles is URL of Google Drive files resource
+const GDriveApiUrlFiles = "https://www.googleapis.com/drive/v3/files"
+
+type gdriveUploader struct {
+	// accessToken is only set if it is given directly instead of account
+	accessToken string
+	account     string
+	tokens      oauth.TokenProvider
+	logger      logger.Logger
+}
+
+type gdriveFileMetadata struct {
+	Name string `json:"name"`
+}
+
+type gdriveFile struct {
+	Id string `json:"id"`
+}
+
+type gdrivePermission struct {
+	Role string `json:"role"`
+	Type string `json:"type"`
+}
+
+// NewGDriveUploader returns new Google Drive uploader that will be using
+// given access token
+func NewGDriveUploader(logger logger.Logger, accessToken string) Uploader {
+	return &gdriveUploader{
+		accessToken: accessToken,
+		tokens:      oauth.NewStaticTokenProvider(accessToken),
+		logger:      logger,
+	}
+}
+
+// NewGDriveAccountUploader returns new Google Drive uploader that will be
+// using OAuth account linked to gateway with a given name
+func NewGDriveAccountUploader(logger logger.Logger, account string) AccountUploader {
+	return &gdriveUploader{
+		account: account,
+		logger:  logger,
+	}
+}
+
+// NewGDriveUploaderWithParams is convenience function that calls
+// NewGDriveUploader or NewGDriveAccountUploader depending on whether param is
+// access token or name of account prefixed with AccountParamPrefix
+func NewGDriveUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be exactly 1 parameter: Google Drive access token or account")
+	}
+	token, account := parseTokenParam(params[0])
+	if account != "" {
+		return NewGDriveAccountUploader(logger, account), nil
+	}
+	return NewGDriveUploader(logger, token), nil
+}
+
+// Name returns Google Drive uploader component name. It is always
+// GDriveUploaderName
+func (gu *gdriveUploader) Name() string {
+	return GDriveUploaderName
+}
+
+// Schema returns schema of Google Drive uploader. It is always GDriveUploaderSchema
+func (gu *gdriveUploader) Schema() common.ComponentSchema {
+	return GDriveUploaderSchema
+}
+
+// Params returns access token or account packed into byte slice
+func (gu *gdriveUploader) Params() [][]byte {
+	return [][]byte{tokenParam(gu.accessToken, gu.account)}
+}
+
+// Account returns name of linked account if it is used
+func (gu *gdriveUploader) Account() string {
+	return gu.account
+}
+
+// SetTokenProvider sets provider of linked account access tokens
+func (gu *gdriveUploader) SetTokenProvider(tokens oauth.TokenProvider) {
+	gu.tokens = tokens
+}
+
+// DownloaderFor returns gdrive downloader with file id as the only param
+func (gu *gdriveUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.GDriveDownloaderName, [][]byte{[]byte(id)}
+}
+
+// Upload uploads given data to Google Drive and returns file id
+func (gu *gdriveUploader) Upload(content []byte) (id string, err error) {
+	return gu.UploadStream(bytes.NewReader(content))
+}
+
+// UploadStream uploads content to Google Drive as it is being read, makes it
+// readable by anyone with link and returns file id
+func (gu *gdriveUploader) UploadStream(content io.Reader) (id string, err error) {
+	gu.logger.Info("Uploading data to Google Drive...")
+	metadata, err := json.Marshal(gdriveFileMetadata{Name: generateRandomFilename()})
+	if err != nil {
+		gu.logger.Errorf("%+v", err)
+		return "", err
+	}
+
+	// multipart body is written as it is being sent
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := writeGDriveMultipart(mw, metadata, content)
+		pw.CloseWithError(err)
+	}()
+
+	r, err := http.NewRequest(http.MethodPost, GDriveApiUrlUpload, pr)
+	if err != nil {
+		pr.Close()
+		gu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	r.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+	var file gdriveFile
+	err = gu.doJSON(r, http.StatusOK, &file)
+	pr.Close()
+	if err != nil {
+		return "", err
+	}
+	gu.logger.Infof("Success uploading data to Google Drive. ID: %s", file.Id)
+
+	permission, err := json.Marshal(gdrivePermission{Role: "reader", Type: "anyone"})
+	if err != nil {
+		gu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	gu.logger.Infof("Sharing file...")
+	r, err = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s/permissions", GDriveApiUrlFiles, file.Id), bytes.NewReader(permission))
+	if err != nil {
+		gu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	err = gu.doJSON(r, http.StatusOK, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return file.Id, nil
+}
+
+// doJSON sends authorized request and unmarshals JSON response into result
+// if it is not nil
+func (gu *gdriveUploader) doJSON(r *http.Request, expectedStatus int, result any) error {
+	token, err := accessToken(gu.tokens, gu.account)
+	if err != nil {
+		gu.logger.Errorf("%+v", err)
+		return err
+	}
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		gu.logger.Errorf("%+v", err)
+		return err
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		gu.logger.Error("Error reading response body")
+		return errors.New("request failed")
+	}
+	if rsp.StatusCode != expectedStatus {
+		gu.logger.Errorf("Request failed with status code: %d", rsp.StatusCode)
+		gu.logger.Errorf(string(body))
+		return errors.New(fmt.Sprintf("request failed with status code: %d", rsp.StatusCode))
+	}
+	if result == nil {
+		return nil
+	}
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		gu.logger.Errorf("Error unmarshalling response body: %s", string(body))
+		return errors.New("request failed")
+	}
+	return nil
+}
+
+func writeGDriveMultipart(mw *multipart.Writer, metadata []byte, content io.Reader) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(metadata)
+	if err != nil {
+		return err
+	}
+	part, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, content)
+	if err != nil {
+		return err
+	}
+	return mw.Close()
+}
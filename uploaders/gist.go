@@ -0,0 +1,169 @@
+package uploaders
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// GistUploaderName is gist uploader component name
+const GistUploaderName = "gist"
+
+// GistUploaderSchema describes gist uploader and its params
+var GistUploaderSchema = common.ComponentSchema{
+	Name:        GistUploaderName,
+	Description: "Uploads data as secret GitHub gist",
+	Params: []common.ParamSchema{
+		{Name: "token", Type: common.ParamTypeString, Description: "GitHub access token", Secret: true},
+		{Name: "apiBase", Type: common.ParamTypeString, Description: "GitHub API URL (i.e. https://github.example.com/api/v3 for GitHub Enterprise Server). Gitea and Forgejo have no gist API and are not supported", Optional: true, Default: GistDefaultApiBase},
+	},
+}
+
+// GistDefaultApiBase is base URL of GitHub REST API used if no other is given
+const GistDefaultApiBase = "https://api.github.com"
+
+type gistUploader struct {
+	logger  logger.Logger
+	token   string
+	apiBase string
+}
+
+type gistFile struct {
+	Content string `json:"content,omitempty"`
+	RawUrl  string `json:"raw_url,omitempty"`
+}
+
+type gistCreateRequestBody struct {
+	Description string              `json:"description"`
+	Public      bool                `json:"public"`
+	Files       map[string]gistFile `json:"files"`
+}
+
+type gistCreateResponseBody struct {
+	Files map[string]gistFile `json:"files"`
+}
+
+// NewGistUploader returns uploader that creates secret gist with data as its
+// only file. API base is base URL of GitHub REST API, so that GitHub
+// Enterprise Server can be used too. Only GitHub gist API is supported (Gitea
+// and Forgejo don't have gists). Gists are text-only, so data should be valid
+// UTF-8 (i.e. encoded with base64 transformer)
+func NewGistUploader(logger logger.Logger, token string, apiBase string) (Uploader, error) {
+	u, err := url.Parse(apiBase)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New(fmt.Sprintf("invalid gist API base: %s", apiBase))
+	}
+	return &gistUploader{
+		logger:  logger,
+		token:   token,
+		apiBase: apiBase,
+	}, nil
+}
+
+// NewGistUploaderWithParams is convenience function that calls
+// NewGistUploader. It does expect access token and optionally API base
+// (GistDefaultApiBase by default)
+func NewGistUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	switch len(params) {
+	case 1:
+		return NewGistUploader(logger, string(params[0]), GistDefaultApiBase)
+	case 2:
+		return NewGistUploader(logger, string(params[0]), string(params[1]))
+	default:
+		return nil, errors.New("there should be 1 or 2 params: access token and optionally API base")
+	}
+}
+
+// Name returns gist uploader name. It is always GistUploaderName
+func (gu *gistUploader) Name() string {
+	return GistUploaderName
+}
+
+// Schema returns schema of gist uploader. It is always GistUploaderSchema
+func (gu *gistUploader) Schema() common.ComponentSchema {
+	return GistUploaderSchema
+}
+
+// Params returns access token and API base packed into byte arrays
+func (gu *gistUploader) Params() [][]byte {
+	return [][]byte{[]byte(gu.token), []byte(gu.apiBase)}
+}
+
+// DownloaderFor returns gist downloader with raw file URL as the only param
+func (gu *gistUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.GistDownloaderName, [][]byte{[]byte(id)}
+}
+
+// Upload creates secret gist with data and returns raw URL of its file
+func (gu *gistUploader) Upload(content []byte) (id string, err error) {
+	if !utf8.Valid(content) {
+		return "", errors.New("gist content should be valid UTF-8, consider using base64 transformer before")
+	}
+
+	filename := generateRandomFilename()
+	reqBody, err := json.Marshal(gistCreateRequestBody{
+		Public: false,
+		Files: map[string]gistFile{
+			filename: {Content: string(content)},
+		},
+	})
+	if err != nil {
+		gu.logger.Errorf("%+v", err)
+		return "", err
+	}
+
+	// API base is already validated during creation
+	apiUrl, _ := url.Parse(gu.apiBase)
+	gu.logger.Infof("Creating gist...")
+	r, err := http.NewRequest(http.MethodPost, apiUrl.JoinPath("gists").String(), bytes.NewReader(reqBody))
+	if err != nil {
+		gu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	r.Header.Set("Authorization", fmt.Sprintf("token %s", gu.token))
+	r.Header.Set("Accept", "application/vnd.github+json")
+	r.Header.Set("Content-Type", "application/json")
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		gu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	defer rsp.Body.Close()
+	rspBodyJson, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		gu.logger.Error("Error reading response body")
+		return "", errors.New("request failed")
+	}
+	if rsp.StatusCode != http.StatusCreated {
+		gu.logger.Errorf("Request failed with status code: %d", rsp.StatusCode)
+		gu.logger.Errorf(string(rspBodyJson))
+		return "", errors.New(fmt.Sprintf("request failed with status code: %d", rsp.StatusCode))
+	}
+
+	var rspBody gistCreateResponseBody
+	err = json.Unmarshal(rspBodyJson, &rspBody)
+	if err != nil {
+		gu.logger.Errorf("Error unmarshalling response body: %s", string(rspBodyJson))
+		return "", errors.New("request failed")
+	}
+	file, ok := rspBody.Files[filename]
+	if !ok || file.RawUrl == "" {
+		return "", errors.New("there is no raw URL of gist file in response")
+	}
+
+	gu.logger.Infof("Success creating gist. Raw URL: %s", file.RawUrl)
+	return file.RawUrl, nil
+}
@@ -0,0 +1,136 @@
+package uploaders
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+const testGistToken = "gist-token"
+
+// stubGitHub implements gist creation endpoint of GitHub REST API and serves
+// raw files of created gists
+type stubGitHub struct {
+	mutex sync.Mutex
+	files map[string]string
+	// public is set if any gist is created public
+	public bool
+}
+
+func newStubGitHub(t *testing.T) (*stubGitHub, *httptest.Server) {
+	stub := &stubGitHub{files: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/gists", stub.create)
+	mux.HandleFunc("/raw/", stub.raw)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return stub, server
+}
+
+func (sg *stubGitHub) create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Authorization") != "token "+testGistToken {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+		return
+	}
+	var body gistCreateRequestBody
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || len(body.Files) != 1 {
+		http.Error(w, `{"message":"Invalid request"}`, http.StatusUnprocessableEntity)
+		return
+	}
+
+	sg.mutex.Lock()
+	defer sg.mutex.Unlock()
+	sg.public = sg.public || body.Public
+	response := gistCreateResponseBody{Files: make(map[string]gistFile)}
+	for name, file := range body.Files {
+		sg.files[name] = file.Content
+		response.Files[name] = gistFile{RawUrl: "http://" + r.Host + "/raw/" + name}
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (sg *stubGitHub) raw(w http.ResponseWriter, r *http.Request) {
+	sg.mutex.Lock()
+	defer sg.mutex.Unlock()
+	content, ok := sg.files[strings.TrimPrefix(r.URL.Path, "/raw/")]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Write([]byte(content))
+}
+
+func TestGistRoundTrip(t *testing.T) {
+	stub, server := newStubGitHub(t)
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	uploader, err := NewGistUploaderWithParams(log, []byte(testGistToken), []byte(server.URL+"/api/v3"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	content := []byte("c2hhZG93bmV0")
+	id, err := uploader.Upload(content)
+	if err != nil {
+		t.Fatalf("upload failed: %+v", err)
+	}
+	stub.mutex.Lock()
+	public := stub.public
+	stub.mutex.Unlock()
+	if public {
+		t.Fatal("gist should be secret")
+	}
+
+	name, params := uploader.DownloaderFor(id)
+	if name != downloaders.GistDownloaderName {
+		t.Fatalf("expected %s downloader, got %s", downloaders.GistDownloaderName, name)
+	}
+	downloader, err := downloaders.NewGistDownloaderWithParams(log, params...)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	downloaded, err := downloader.Download()
+	if err != nil {
+		t.Fatalf("download failed: %+v", err)
+	}
+	if string(downloaded) != string(content) {
+		t.Fatalf("downloaded content differs: %q", downloaded)
+	}
+}
+
+func TestGistWrongToken(t *testing.T) {
+	_, server := newStubGitHub(t)
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	uploader, err := NewGistUploader(log, "wrong", server.URL+"/api/v3")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err = uploader.Upload([]byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected error with status code, got %v", err)
+	}
+}
+
+func TestGistBinaryContent(t *testing.T) {
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	// request is not made, so API base doesn't matter
+	uploader, err := NewGistUploader(log, testGistToken, "http://127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err = uploader.Upload([]byte{0xff, 0xfe})
+	if err == nil || !strings.Contains(err.Error(), "UTF-8") {
+		t.Fatalf("binary content should be rejected, got %v", err)
+	}
+}
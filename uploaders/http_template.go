@@ -0,0 +1,364 @@
+package uploaders
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// HTTPTemplateUploaderName is http-template uploader component name
+const HTTPTemplateUploaderName = "http-template"
+
+// HTTPTemplateUploaderSchema describes http-template uploader and its params
+var HTTPTemplateUploaderSchema = common.ComponentSchema{
+	Name:        HTTPTemplateUploaderName,
+	Description: "Uploads data to arbitrary host described by template",
+	Params: []common.ParamSchema{
+		{Name: "config", Type: common.ParamTypeString, Description: "JSON config of request and response (see HTTPTemplateConfig)", Secret: true},
+	},
+}
+
+const (
+	// HTTPTemplateBodyRaw sends content as request body as is
+	HTTPTemplateBodyRaw = "raw"
+	// HTTPTemplateBodyForm sends content as field of URL encoded form
+	HTTPTemplateBodyForm = "form"
+	// HTTPTemplateBodyMultipart sends content as file field of multipart form
+	HTTPTemplateBodyMultipart = "multipart"
+)
+
+// HTTPTemplateFilenamePlaceholder is replaced with random file name in URL
+// and multipart file name
+const HTTPTemplateFilenamePlaceholder = "{{filename}}"
+
+// HTTPTemplateIdPlaceholder is replaced with extracted id in download URL
+const HTTPTemplateIdPlaceholder = "{{id}}"
+
+// HTTPTemplateConfig describes how to upload content to a host and how to
+// get its URL back
+type HTTPTemplateConfig struct {
+	// Method is HTTP method, POST by default
+	Method string `json:"method,omitempty"`
+	// URL is URL to send request to. It may contain filename placeholder
+	URL string `json:"url"`
+	// Headers are additional request headers
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is one of raw, form or multipart (default)
+	Body string `json:"body,omitempty"`
+	// Field is name of form field containing content, "file" by default
+	Field string `json:"field,omitempty"`
+	// Fields are additional form fields
+	Fields map[string]string `json:"fields,omitempty"`
+	// Filename is file name of multipart field. It is random by default
+	Filename string `json:"filename,omitempty"`
+	// Regex extracts id from response body. If it has groups, the first one
+	// is id, otherwise the whole match
+	Regex string `json:"regex,omitempty"`
+	// JSONPath extracts id from JSON response body (i.e. $.data.files[0].url)
+	JSONPath string `json:"jsonPath,omitempty"`
+	// DownloadURL is template of URL to download content from with id
+	// placeholder. Id itself is used as URL by default
+	DownloadURL string `json:"downloadUrl,omitempty"`
+}
+
+type httpTemplateUploader struct {
+	logger   logger.Logger
+	config   HTTPTemplateConfig
+	regex    *regexp.Regexp
+	jsonPath []jsonPathStep
+}
+
+// NewHTTPTemplateUploader returns uploader that uploads content to any host
+// described by config. Uploaded content is downloaded with web downloader
+func NewHTTPTemplateUploader(logger logger.Logger, config HTTPTemplateConfig) (Uploader, error) {
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.Body == "" {
+		config.Body = HTTPTemplateBodyMultipart
+	}
+	if config.Field == "" {
+		config.Field = "file"
+	}
+	if config.Body != HTTPTemplateBodyRaw && config.Body != HTTPTemplateBodyForm && config.Body != HTTPTemplateBodyMultipart {
+		return nil, errors.New(fmt.Sprintf("unknown body layout: %s", config.Body))
+	}
+	u, err := url.Parse(strings.ReplaceAll(config.URL, HTTPTemplateFilenamePlaceholder, "x"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New(fmt.Sprintf("invalid upload URL: %s", config.URL))
+	}
+	if config.Regex != "" && config.JSONPath != "" {
+		return nil, errors.New("only one of regex and JSONPath can be set")
+	}
+
+	htu := &httpTemplateUploader{
+		logger: logger,
+		config: config,
+	}
+	if config.Regex != "" {
+		htu.regex, err = regexp.Compile(config.Regex)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.JSONPath != "" {
+		htu.jsonPath, err = parseJSONPath(config.JSONPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return htu, nil
+}
+
+// NewHTTPTemplateUploaderWithParams is convenience function that calls
+// NewHTTPTemplateUploader. It does expect single param that is JSON config
+func NewHTTPTemplateUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	if len(params) != 1 {
+		return nil, errors.New("there should be 1 param: JSON config")
+	}
+	var config HTTPTemplateConfig
+	err := json.Unmarshal(params[0], &config)
+	if err != nil {
+		return nil, err
+	}
+	return NewHTTPTemplateUploader(logger, config)
+}
+
+// Name returns http-template uploader name. It is always
+// HTTPTemplateUploaderName
+func (htu *httpTemplateUploader) Name() string {
+	return HTTPTemplateUploaderName
+}
+
+// Schema returns schema of http-template uploader. It is always HTTPTemplateUploaderSchema
+func (htu *httpTemplateUploader) Schema() common.ComponentSchema {
+	return HTTPTemplateUploaderSchema
+}
+
+// Params returns JSON config packed into byte array
+func (htu *httpTemplateUploader) Params() [][]byte {
+	config, _ := json.Marshal(htu.config)
+	return [][]byte{config}
+}
+
+// DownloaderFor returns web downloader with download URL made from id
+func (htu *httpTemplateUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	downloadUrl := id
+	if htu.config.DownloadURL != "" {
+		downloadUrl = strings.ReplaceAll(htu.config.DownloadURL, HTTPTemplateIdPlaceholder, id)
+	}
+	return downloaders.WebDownloaderName, [][]byte{[]byte(downloadUrl)}
+}
+
+// Upload uploads data as described by config and returns extracted id
+func (htu *httpTemplateUploader) Upload(content []byte) (id string, err error) {
+	return htu.UploadStream(bytes.NewReader(content))
+}
+
+// UploadStream uploads content as described by config and returns extracted
+// id. Content is streamed unless it is sent as URL encoded form
+func (htu *httpTemplateUploader) UploadStream(content io.Reader) (id string, err error) {
+	filename := generateRandomFilename()
+	if htu.config.Filename != "" {
+		filename = strings.ReplaceAll(htu.config.Filename, HTTPTemplateFilenamePlaceholder, filename)
+	}
+
+	var body io.Reader
+	var contentType string
+	switch htu.config.Body {
+	case HTTPTemplateBodyRaw:
+		body = content
+		contentType = "application/octet-stream"
+	case HTTPTemplateBodyForm:
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return "", err
+		}
+		form := url.Values{}
+		for name, value := range htu.config.Fields {
+			form.Set(name, value)
+		}
+		form.Set(htu.config.Field, string(data))
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case HTTPTemplateBodyMultipart:
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		mw := multipart.NewWriter(pw)
+		go func() {
+			pw.CloseWithError(htu.writeMultipart(mw, filename, content))
+		}()
+		body = pr
+		contentType = mw.FormDataContentType()
+	}
+
+	uploadUrl := strings.ReplaceAll(htu.config.URL, HTTPTemplateFilenamePlaceholder, filename)
+	r, err := http.NewRequest(htu.config.Method, uploadUrl, body)
+	if err != nil {
+		htu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	r.Header.Set("Content-Type", contentType)
+	for name, value := range htu.config.Headers {
+		r.Header.Set(name, value)
+	}
+
+	htu.logger.Infof("Uploading data to %s", uploadUrl)
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		htu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	defer rsp.Body.Close()
+	rspBody, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		htu.logger.Error("Error reading response body")
+		return "", errors.New("request failed")
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		htu.logger.Errorf("Request failed with status code: %d", rsp.StatusCode)
+		htu.logger.Errorf(string(rspBody))
+		return "", errors.New(fmt.Sprintf("request failed with status code: %d", rsp.StatusCode))
+	}
+
+	id, err = htu.extractId(rspBody)
+	if err != nil {
+		htu.logger.Errorf("Error extracting id from response: %s", string(rspBody))
+		return "", err
+	}
+	htu.logger.Infof("Success uploading data. ID: %s", id)
+	return id, nil
+}
+
+func (htu *httpTemplateUploader) writeMultipart(mw *multipart.Writer, filename string, content io.Reader) error {
+	for name, value := range htu.config.Fields {
+		err := mw.WriteField(name, value)
+		if err != nil {
+			return err
+		}
+	}
+	part, err := mw.CreateFormFile(htu.config.Field, filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, content)
+	if err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// extractId returns id from response body using regex or JSONPath. If none
+// is set, the whole body is id
+func (htu *httpTemplateUploader) extractId(body []byte) (string, error) {
+	switch {
+	case htu.regex != nil:
+		groups := htu.regex.FindSubmatch(body)
+		if groups == nil {
+			return "", errors.New("failed to capture id")
+		}
+		if len(groups) > 1 {
+			return string(groups[1]), nil
+		}
+		return string(groups[0]), nil
+	case htu.jsonPath != nil:
+		// numbers are kept as is, so that long numeric ids don't lose digits
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var data any
+		err := decoder.Decode(&data)
+		if err != nil {
+			return "", err
+		}
+		return evalJSONPath(data, htu.jsonPath)
+	default:
+		id := strings.TrimSpace(string(body))
+		if id == "" {
+			return "", errors.New("failed to capture id")
+		}
+		return id, nil
+	}
+}
+
+// jsonPathStep is either object key or array index
+type jsonPathStep struct {
+	key   string
+	index int
+}
+
+var jsonPathStepPattern = regexp.MustCompile(`^(?:\.([^.\[\]]+)|\[(\d+)\]|\['([^']*)'\])`)
+
+// parseJSONPath parses simple subset of JSONPath consisting of object keys
+// (.key or ['key']) and array indexes ([0]) starting from root ($)
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.New(fmt.Sprintf("JSONPath should start with $: %s", path))
+	}
+	rest := path[1:]
+	steps := make([]jsonPathStep, 0)
+	for rest != "" {
+		groups := jsonPathStepPattern.FindStringSubmatch(rest)
+		if groups == nil {
+			return nil, errors.New(fmt.Sprintf("unsupported JSONPath: %s", path))
+		}
+		switch {
+		case groups[2] != "":
+			index, err := strconv.Atoi(groups[2])
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, jsonPathStep{index: index})
+		case groups[1] != "":
+			steps = append(steps, jsonPathStep{key: groups[1], index: -1})
+		default:
+			steps = append(steps, jsonPathStep{key: groups[3], index: -1})
+		}
+		rest = rest[len(groups[0]):]
+	}
+	return steps, nil
+}
+
+// evalJSONPath returns string or number value by parsed JSONPath
+func evalJSONPath(data any, steps []jsonPathStep) (string, error) {
+	for _, step := range steps {
+		if step.index >= 0 {
+			array, ok := data.([]any)
+			if !ok || step.index >= len(array) {
+				return "", errors.New(fmt.Sprintf("there is no element %d in response", step.index))
+			}
+			data = array[step.index]
+			continue
+		}
+		object, ok := data.(map[string]any)
+		if !ok {
+			return "", errors.New(fmt.Sprintf("there is no field %s in response", step.key))
+		}
+		data, ok = object[step.key]
+		if !ok {
+			return "", errors.New(fmt.Sprintf("there is no field %s in response", step.key))
+		}
+	}
+	switch value := data.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	default:
+		return "", errors.New("value by JSONPath is neither string nor number")
+	}
+}
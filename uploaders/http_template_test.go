@@ -0,0 +1,291 @@
+package uploaders
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []jsonPathStep
+		fails    bool
+	}{
+		{"$", []jsonPathStep{}, false},
+		{"$.url", []jsonPathStep{{key: "url", index: -1}}, false},
+		{"$['file name']", []jsonPathStep{{key: "file name", index: -1}}, false},
+		{"$['']", []jsonPathStep{{key: "", index: -1}}, false},
+		{"$[2]", []jsonPathStep{{index: 2}}, false},
+		{"$.data.files[0]['url']", []jsonPathStep{{key: "data", index: -1}, {key: "files", index: -1}, {index: 0}, {key: "url", index: -1}}, false},
+		{"data.url", nil, true},
+		{"$..url", nil, true},
+		{"$[*]", nil, true},
+		{"$[-1]", nil, true},
+		{"$.a[0", nil, true},
+		{"$[\"url\"]", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			steps, err := parseJSONPath(test.path)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", steps)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if !reflect.DeepEqual(steps, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, steps)
+			}
+		})
+	}
+}
+
+func TestExtractId(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   HTTPTemplateConfig
+		body     string
+		expected string
+		fails    bool
+	}{
+		{"whole body", HTTPTemplateConfig{}, " https://host/abc\n", "https://host/abc", false},
+		{"empty body", HTTPTemplateConfig{}, " \n", "", true},
+		{"regex group", HTTPTemplateConfig{Regex: `href="([^"]+)"`}, `<a href="https://host/abc">`, "https://host/abc", false},
+		{"regex whole match", HTTPTemplateConfig{Regex: `https://host/\w+`}, `see https://host/abc.`, "https://host/abc", false},
+		{"regex without match", HTTPTemplateConfig{Regex: `id=(\d+)`}, `error`, "", true},
+		{"key", HTTPTemplateConfig{JSONPath: "$.url"}, `{"url":"https://host/abc"}`, "https://host/abc", false},
+		{"quoted key", HTTPTemplateConfig{JSONPath: "$['file-url']"}, `{"file-url":"https://host/abc"}`, "https://host/abc", false},
+		{"index", HTTPTemplateConfig{JSONPath: "$.files[1].id"}, `{"files":[{"id":"a"},{"id":"b"}]}`, "b", false},
+		{"root array", HTTPTemplateConfig{JSONPath: "$[0]"}, `["a"]`, "a", false},
+		{"integer", HTTPTemplateConfig{JSONPath: "$.id"}, `{"id":12345678901234567890}`, "12345678901234567890", false},
+		{"fraction", HTTPTemplateConfig{JSONPath: "$.id"}, `{"id":1.5}`, "1.5", false},
+		{"missing key", HTTPTemplateConfig{JSONPath: "$.url"}, `{"link":"x"}`, "", true},
+		{"key of array", HTTPTemplateConfig{JSONPath: "$.url"}, `["x"]`, "", true},
+		{"index out of range", HTTPTemplateConfig{JSONPath: "$.files[2]"}, `{"files":["a","b"]}`, "", true},
+		{"index of object", HTTPTemplateConfig{JSONPath: "$[0]"}, `{"0":"a"}`, "", true},
+		{"object value", HTTPTemplateConfig{JSONPath: "$.file"}, `{"file":{"url":"x"}}`, "", true},
+		{"boolean value", HTTPTemplateConfig{JSONPath: "$.ok"}, `{"ok":true}`, "", true},
+		{"null value", HTTPTemplateConfig{JSONPath: "$.url"}, `{"url":null}`, "", true},
+		{"not JSON", HTTPTemplateConfig{JSONPath: "$.url"}, `<html>`, "", true},
+	}
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.URL = "https://host/upload"
+			uploader, err := NewHTTPTemplateUploader(log, test.config)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			id, err := uploader.(*httpTemplateUploader).extractId([]byte(test.body))
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %q", id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if id != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, id)
+			}
+		})
+	}
+}
+
+// stubHost stores uploaded files sent in any of body layouts and serves them
+type stubHost struct {
+	mutex   sync.Mutex
+	files   map[string][]byte
+	request *http.Request
+	fields  map[string]string
+}
+
+func newStubHost(t *testing.T) (*stubHost, *httptest.Server) {
+	host := &stubHost{files: make(map[string][]byte)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/raw/", host.raw)
+	mux.HandleFunc("/form", host.form)
+	mux.HandleFunc("/multipart", host.multipart)
+	mux.HandleFunc("/files/", host.download)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return host, server
+}
+
+func (sh *stubHost) raw(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+	name := strings.TrimPrefix(r.URL.Path, "/raw/")
+	sh.save(r, name, data, nil)
+	io.WriteString(w, "http://"+r.Host+"/files/"+name)
+}
+
+func (sh *stubHost) form(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields := make(map[string]string)
+	for name := range r.PostForm {
+		fields[name] = r.PostForm.Get(name)
+	}
+	sh.save(r, "form", []byte(r.PostForm.Get("content")), fields)
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "data": map[string]string{"id": "form"}})
+}
+
+func (sh *stubHost) multipart(w http.ResponseWriter, r *http.Request) {
+	file, header, err := r.FormFile("upload")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, _ := io.ReadAll(file)
+	fields := make(map[string]string)
+	for name := range r.MultipartForm.Value {
+		fields[name] = r.MultipartForm.Value[name][0]
+	}
+	sh.save(r, header.Filename, data, fields)
+	io.WriteString(w, `<a href="/files/`+header.Filename+`">uploaded</a>`)
+}
+
+func (sh *stubHost) save(r *http.Request, name string, data []byte, fields map[string]string) {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	sh.files[name] = data
+	sh.request = r
+	sh.fields = fields
+}
+
+func (sh *stubHost) download(w http.ResponseWriter, r *http.Request) {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	data, ok := sh.files[strings.TrimPrefix(r.URL.Path, "/files/")]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Write(data)
+}
+
+func TestHTTPTemplateRoundTrip(t *testing.T) {
+	host, server := newStubHost(t)
+	tests := []struct {
+		name   string
+		config HTTPTemplateConfig
+		method string
+		fields map[string]string
+	}{
+		{
+			name: HTTPTemplateBodyRaw,
+			config: HTTPTemplateConfig{
+				Method:  http.MethodPut,
+				URL:     server.URL + "/raw/" + HTTPTemplateFilenamePlaceholder,
+				Body:    HTTPTemplateBodyRaw,
+				Headers: map[string]string{"Authorization": "Bearer token"},
+			},
+			method: http.MethodPut,
+		},
+		{
+			name: HTTPTemplateBodyForm,
+			config: HTTPTemplateConfig{
+				URL:         server.URL + "/form",
+				Body:        HTTPTemplateBodyForm,
+				Field:       "content",
+				Fields:      map[string]string{"expiry": "never"},
+				Headers:     map[string]string{"Authorization": "Bearer token"},
+				JSONPath:    "$.data.id",
+				DownloadURL: server.URL + "/files/" + HTTPTemplateIdPlaceholder,
+			},
+			method: http.MethodPost,
+			fields: map[string]string{"content": "content of upload", "expiry": "never"},
+		},
+		{
+			name: HTTPTemplateBodyMultipart,
+			config: HTTPTemplateConfig{
+				URL:         server.URL + "/multipart",
+				Field:       "upload",
+				Fields:      map[string]string{"public": "1"},
+				Headers:     map[string]string{"Authorization": "Bearer token"},
+				Filename:    "shadownet-" + HTTPTemplateFilenamePlaceholder,
+				Regex:       `href="/files/([^"]+)"`,
+				DownloadURL: server.URL + "/files/" + HTTPTemplateIdPlaceholder,
+			},
+			method: http.MethodPost,
+			fields: map[string]string{"public": "1"},
+		},
+	}
+
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	content := []byte("content of upload")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			uploader, err := NewHTTPTemplateUploader(log, test.config)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			id, err := uploader.Upload(content)
+			if err != nil {
+				t.Fatalf("upload failed: %+v", err)
+			}
+
+			host.mutex.Lock()
+			request, fields := host.request, host.fields
+			host.mutex.Unlock()
+			if request.Method != test.method {
+				t.Fatalf("expected %s request, got %s", test.method, request.Method)
+			}
+			if request.Header.Get("Authorization") != "Bearer token" {
+				t.Fatal("headers are not sent")
+			}
+			if test.fields != nil && !reflect.DeepEqual(fields, test.fields) {
+				t.Fatalf("expected fields %v, got %v", test.fields, fields)
+			}
+
+			name, params := uploader.DownloaderFor(id)
+			if name != downloaders.WebDownloaderName {
+				t.Fatalf("expected %s downloader, got %s", downloaders.WebDownloaderName, name)
+			}
+			downloader, err := downloaders.NewWebDownloaderWithParams(log, params...)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			downloaded, err := downloader.Download()
+			if err != nil {
+				t.Fatalf("download failed: %+v", err)
+			}
+			if string(downloaded) != string(content) {
+				t.Fatalf("downloaded content differs: %q", downloaded)
+			}
+		})
+	}
+}
+
+func TestHTTPTemplateErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusForbidden)
+	}))
+	t.Cleanup(server.Close)
+
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	uploader, err := NewHTTPTemplateUploader(log, HTTPTemplateConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err = uploader.Upload([]byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected error with status code, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package uploaders
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// IPFSUploaderName is ipfs uploader component name
+const IPFSUploaderName = "ipfs"
+
+// IPFSUploaderSchema describes IPFS uploader and its params
+var IPFSUploaderSchema = common.ComponentSchema{
+	Name:        IPFSUploaderName,
+	Description: "Adds data to IPFS node and optionally pins it remotely",
+	Params: []common.ParamSchema{
+		{Name: "api", Type: common.ParamTypeString, Description: "RPC API address"},
+		{Name: "gateway", Type: common.ParamTypeString, Description: "public gateway URL put into ShadowNet URL"},
+		{Name: "pinEndpoint", Type: common.ParamTypeString, Description: "remote pinning service endpoint", Optional: true},
+		{Name: "pinToken", Type: common.ParamTypeString, Description: "remote pinning service access token", Secret: true, Optional: true},
+	},
+}
+
+type ipfsUploader struct {
+	logger      logger.Logger
+	apiAddr     string
+	gateway     string
+	pinEndpoint string
+	pinToken    string
+}
+
+type ipfsAddResponseBody struct {
+	Name string `json:"Name"`
+	Hash string `json:"Hash"`
+}
+
+type ipfsPinRequestBody struct {
+	Cid  string `json:"cid"`
+	Name string `json:"name"`
+}
+
+// NewIPFSUploader returns uploader that adds data to IPFS node with a given
+// RPC API address (i.e. http://127.0.0.1:5001) and returns CID. Content is
+// downloaded through gateway, which is required: RPC API is usually private
+// and lets anyone who knows it change the node, so it is never put into
+// ShadowNet URL. If pin endpoint is given, content is also pinned to remote
+// service implementing IPFS Pinning Service API
+func NewIPFSUploader(logger logger.Logger, apiAddr, gateway, pinEndpoint, pinToken string) (Uploader, error) {
+	if err := downloaders.ValidateIPFSBase(apiAddr); err != nil {
+		return nil, err
+	}
+	if gateway == "" {
+		return nil, errors.New("IPFS gateway is required, RPC API address is not put into URL")
+	}
+	if err := downloaders.ValidateIPFSBase(gateway); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(strings.TrimSuffix(gateway, "/"), downloaders.IPFSApiPath) {
+		return nil, errors.New(fmt.Sprintf("IPFS gateway should not be RPC API URL: %s", gateway))
+	}
+	// pin endpoint is optional
+	if pinEndpoint != "" {
+		if err := downloaders.ValidateIPFSBase(pinEndpoint); err != nil {
+			return nil, err
+		}
+	}
+	return &ipfsUploader{
+		logger:      logger,
+		apiAddr:     strings.TrimSuffix(apiAddr, "/"),
+		gateway:     gateway,
+		pinEndpoint: strings.TrimSuffix(pinEndpoint, "/"),
+		pinToken:    pinToken,
+	}, nil
+}
+
+// NewIPFSUploaderWithParams is convenience function that calls
+// NewIPFSUploader. It does expect RPC API address, gateway URL and optionally
+// pin endpoint with its access token
+func NewIPFSUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	switch len(params) {
+	case 2:
+		return NewIPFSUploader(logger, string(params[0]), string(params[1]), "", "")
+	case 4:
+		return NewIPFSUploader(logger, string(params[0]), string(params[1]), string(params[2]), string(params[3]))
+	default:
+		return nil, errors.New("there should be 2 or 4 params: RPC API address, gateway URL, pin endpoint and pin access token")
+	}
+}
+
+// Name returns ipfs uploader name. It is always IPFSUploaderName
+func (iu *ipfsUploader) Name() string {
+	return IPFSUploaderName
+}
+
+// Schema returns schema of IPFS uploader. It is always IPFSUploaderSchema
+func (iu *ipfsUploader) Schema() common.ComponentSchema {
+	return IPFSUploaderSchema
+}
+
+// Params returns RPC API address, gateway URL and remote pinning params
+// packed into byte arrays
+func (iu *ipfsUploader) Params() [][]byte {
+	params := [][]byte{[]byte(iu.apiAddr), []byte(iu.gateway)}
+	if iu.pinEndpoint != "" {
+		params = append(params, []byte(iu.pinEndpoint), []byte(iu.pinToken))
+	}
+	return params
+}
+
+// DownloaderFor returns ipfs downloader with gateway URL and CID as params
+func (iu *ipfsUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.IPFSDownloaderName, [][]byte{[]byte(iu.gateway), []byte(id)}
+}
+
+// Upload adds data to IPFS and returns its CID
+func (iu *ipfsUploader) Upload(content []byte) (id string, err error) {
+	return iu.UploadStream(bytes.NewReader(content))
+}
+
+// UploadStream adds content to IPFS as it is being read and returns its CID
+func (iu *ipfsUploader) UploadStream(content io.Reader) (id string, err error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", generateRandomFilename())
+		if err == nil {
+			_, err = io.Copy(part, content)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	query := url.Values{}
+	query.Set("cid-version", "1")
+	query.Set("pin", "true")
+	query.Set("progress", "false")
+	iu.logger.Infof("Adding data to IPFS node %s", iu.apiAddr)
+	rsp, err := http.Post(iu.apiAddr+downloaders.IPFSApiPath+"/add?"+query.Encode(), mw.FormDataContentType(), pr)
+	pr.Close()
+	if err != nil {
+		iu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		iu.logger.Error("Error reading response body")
+		return "", errors.New("request failed")
+	}
+	if rsp.StatusCode != http.StatusOK {
+		iu.logger.Errorf("Request failed with status code: %d", rsp.StatusCode)
+		iu.logger.Errorf(string(body))
+		return "", errors.New(fmt.Sprintf("request failed with status code: %d", rsp.StatusCode))
+	}
+	var added ipfsAddResponseBody
+	err = json.Unmarshal(body, &added)
+	if err != nil || added.Hash == "" {
+		iu.logger.Errorf("Error unmarshalling response body: %s", string(body))
+		return "", errors.New("request failed")
+	}
+	iu.logger.Infof("Success adding data to IPFS. CID: %s", added.Hash)
+
+	if iu.pinEndpoint != "" {
+		err = iu.pinRemotely(added.Hash)
+		if err != nil {
+			return "", err
+		}
+	}
+	return added.Hash, nil
+}
+
+// pinRemotely asks remote pinning service to keep content with a given CID
+func (iu *ipfsUploader) pinRemotely(cid string) error {
+	body, err := json.Marshal(ipfsPinRequestBody{Cid: cid, Name: cid})
+	if err != nil {
+		iu.logger.Errorf("%+v", err)
+		return err
+	}
+	iu.logger.Infof("Pinning %s to %s", cid, iu.pinEndpoint)
+	r, err := http.NewRequest(http.MethodPost, iu.pinEndpoint+"/pins", bytes.NewReader(body))
+	if err != nil {
+		iu.logger.Errorf("%+v", err)
+		return err
+	}
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", iu.pinToken))
+	r.Header.Set("Content-Type", "application/json")
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		iu.logger.Errorf("%+v", err)
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusAccepted && rsp.StatusCode != http.StatusOK {
+		iu.logger.Errorf("Request failed with status code: %d", rsp.StatusCode)
+		return errors.New(fmt.Sprintf("pin request failed with status code: %d", rsp.StatusCode))
+	}
+	return nil
+}
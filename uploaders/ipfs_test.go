@@ -0,0 +1,184 @@
+package uploaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+const testIPFSPinToken = "pin-token"
+
+// stubIPFS implements RPC endpoints of IPFS node used by ShadowNet, HTTP
+// gateway and remote pinning service in the same server
+type stubIPFS struct {
+	mutex   sync.Mutex
+	content map[string][]byte
+	pinned  []string
+}
+
+func newStubIPFS(t *testing.T) (*stubIPFS, *httptest.Server) {
+	stub := &stubIPFS{content: make(map[string][]byte)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/add", stub.add)
+	mux.HandleFunc("/api/v0/cat", stub.cat)
+	mux.HandleFunc("/ipfs/", stub.gateway)
+	mux.HandleFunc("/pins", stub.pin)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return stub, server
+}
+
+func (si *stubIPFS) add(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, _ := io.ReadAll(file)
+	hash := sha256.Sum256(data)
+	cid := "bafk" + hex.EncodeToString(hash[:16])
+
+	si.mutex.Lock()
+	si.content[cid] = data
+	si.mutex.Unlock()
+	json.NewEncoder(w).Encode(ipfsAddResponseBody{Name: cid, Hash: cid})
+}
+
+func (si *stubIPFS) cat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	si.write(w, r.URL.Query().Get("arg"))
+}
+
+func (si *stubIPFS) gateway(w http.ResponseWriter, r *http.Request) {
+	si.write(w, strings.TrimPrefix(r.URL.Path, "/ipfs/"))
+}
+
+func (si *stubIPFS) write(w http.ResponseWriter, cid string) {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	data, ok := si.content[cid]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Write(data)
+}
+
+func (si *stubIPFS) pin(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testIPFSPinToken {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var request ipfsPinRequestBody
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	si.mutex.Lock()
+	si.pinned = append(si.pinned, request.Cid)
+	si.mutex.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func TestIPFSRoundTrip(t *testing.T) {
+	stub, server := newStubIPFS(t)
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	uploader, err := NewIPFSUploader(log, server.URL, server.URL, server.URL, testIPFSPinToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("location-independent content")
+	id, err := uploader.Upload(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.mutex.Lock()
+	if len(stub.pinned) != 1 || stub.pinned[0] != id {
+		t.Errorf("expected %s to be pinned, got %v", id, stub.pinned)
+	}
+	stub.mutex.Unlock()
+
+	name, params := uploader.DownloaderFor(id)
+	if name != downloaders.IPFSDownloaderName || string(params[0]) != server.URL {
+		t.Fatalf("expected ipfs downloader with gateway %s, got %s with %s", server.URL, name, params[0])
+	}
+	downloader, err := downloaders.NewIPFSDownloaderWithParams(log, params...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := downloader.Download()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Errorf("expected %q, got %q", content, downloaded)
+	}
+}
+
+func TestIPFSCat(t *testing.T) {
+	_, server := newStubIPFS(t)
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	uploader, err := NewIPFSUploader(log, server.URL, server.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("content got from node")
+	id, err := uploader.Upload(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloader, err := downloaders.NewIPFSDownloader(log, server.URL+downloaders.IPFSApiPath, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := downloader.Download()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Errorf("expected %q, got %q", content, downloaded)
+	}
+}
+
+func TestIPFSWrongPinToken(t *testing.T) {
+	_, server := newStubIPFS(t)
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	uploader, err := NewIPFSUploader(log, server.URL, server.URL, server.URL, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = uploader.Upload([]byte("data"))
+	if err == nil {
+		t.Error("upload with wrong pin token should fail")
+	}
+}
+
+func TestIPFSGatewayRequired(t *testing.T) {
+	log := logger.NewZerologLogger(logger.NewZerologLoggerConfig())
+	for _, gateway := range []string{"", "http://127.0.0.1:5001/api/v0"} {
+		_, err := NewIPFSUploader(log, "http://127.0.0.1:5001", gateway, "", "")
+		if err == nil {
+			t.Errorf("uploader with gateway %q should not be created", gateway)
+		}
+	}
+}
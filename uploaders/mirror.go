@@ -0,0 +1,165 @@
+package uploaders
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// MirrorUploaderName is mirror uploader component name
+const MirrorUploaderName = "mirror"
+
+// MirrorUploaderSchema describes mirror uploader and its params
+var MirrorUploaderSchema = common.ComponentSchema{
+	Name:        MirrorUploaderName,
+	Description: "Uploads data to all the following uploaders",
+	Params: []common.ParamSchema{
+		{Name: "minMirrors", Type: common.ParamTypeInt, Description: "minimum number of mirrors that should be uploaded, all of them by default", Optional: true},
+	},
+}
+
+type mirrorUploader struct {
+	logger     logger.Logger
+	minMirrors int
+	uploaders  []Uploader
+	mutex      sync.Mutex
+	warnings   []string
+}
+
+// NewMirrorUploader returns uploader that uploads the same data with every
+// uploader added with AddUploaders. Locations of all copies are kept in
+// ShadowNet URL in the same order as uploaders, so that download can fall
+// back to the next one if the previous is not available. Upload fails if
+// less than minMirrors mirrors are uploaded (all of them if it is 0), the
+// other failures are reported as warnings
+func NewMirrorUploader(logger logger.Logger, minMirrors int) (CompositeUploader, error) {
+	if minMirrors < 0 {
+		return nil, errors.New("minimum number of mirrors should not be negative")
+	}
+	return &mirrorUploader{
+		logger:     logger,
+		minMirrors: minMirrors,
+	}, nil
+}
+
+// NewMirrorUploaderWithParams is convenience function that calls
+// NewMirrorUploader. Minimum number of mirrors in decimal form is the only
+// optional param
+func NewMirrorUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	switch len(params) {
+	case 0:
+		return NewMirrorUploader(logger, 0)
+	case 1:
+		minMirrors, err := strconv.Atoi(string(params[0]))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid minimum number of mirrors: %s", params[0]))
+		}
+		return NewMirrorUploader(logger, minMirrors)
+	default:
+		return nil, errors.New("there should be at most 1 parameter: minimum number of mirrors")
+	}
+}
+
+// Name returns mirror uploader component name. It is always
+// MirrorUploaderName
+func (mu *mirrorUploader) Name() string {
+	return MirrorUploaderName
+}
+
+// Schema returns schema of mirror uploader. It is always MirrorUploaderSchema
+func (mu *mirrorUploader) Schema() common.ComponentSchema {
+	return MirrorUploaderSchema
+}
+
+// Params returns minimum number of mirrors in decimal form if it is set
+func (mu *mirrorUploader) Params() [][]byte {
+	if mu.minMirrors == 0 {
+		return nil
+	}
+	return [][]byte{[]byte(strconv.Itoa(mu.minMirrors))}
+}
+
+// Warnings returns mirrors that failed to upload while enough of the others
+// succeeded
+func (mu *mirrorUploader) Warnings() []string {
+	mu.mutex.Lock()
+	warnings := append([]string{}, mu.warnings...)
+	mu.mutex.Unlock()
+	return append(warnings, collectWarnings(mu.uploaders...)...)
+}
+
+// AddUploaders adds uploaders for copies of data
+func (mu *mirrorUploader) AddUploaders(uploaders ...Uploader) error {
+	mu.uploaders = append(mu.uploaders, uploaders...)
+	return nil
+}
+
+// DownloaderFor returns mirror downloader with params packed into id
+func (mu *mirrorUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	params, err := unpackDownloaderParams(id)
+	if err != nil {
+		mu.logger.Errorf("%+v", err)
+	}
+	return downloaders.MirrorDownloaderName, params
+}
+
+// Upload uploads data with all uploaders in parallel. Failed uploads are
+// skipped and reported as warnings if there are at least minimum number of
+// successful ones
+func (mu *mirrorUploader) Upload(content []byte) (id string, err error) {
+	if len(mu.uploaders) == 0 {
+		return "", errors.New("there are no uploaders for mirrors")
+	}
+	minMirrors := mu.minMirrors
+	if minMirrors == 0 || minMirrors > len(mu.uploaders) {
+		minMirrors = len(mu.uploaders)
+	}
+
+	mirrors := make([][]byte, len(mu.uploaders))
+	errs := make([]error, len(mu.uploaders))
+	var wg sync.WaitGroup
+	for i := range mu.uploaders {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uploader := mu.uploaders[i]
+			mu.logger.Infof("Uploading mirror %d to %s", i, uploader.Name())
+			mirrorID, err := uploader.Upload(content)
+			if err != nil {
+				mu.logger.Errorf("Error uploading mirror %d to %s: %+v", i, uploader.Name(), err)
+				errs[i] = err
+				return
+			}
+			mirrors[i], errs[i] = downloaderSpec(uploader, mirrorID)
+		}(i)
+	}
+	wg.Wait()
+
+	hash := sha256.Sum256(content)
+	params := [][]byte{hash[:]}
+	var failures []string
+	for i, mirror := range mirrors {
+		if mirror != nil {
+			params = append(params, mirror)
+		} else {
+			failures = append(failures, fmt.Sprintf("mirror %d (%s) is not uploaded: %+v", i, mu.uploaders[i].Name(), errs[i]))
+		}
+	}
+	if len(params)-1 < minMirrors {
+		return "", errors.New(fmt.Sprintf("only %d of %d mirrors are uploaded, %d needed: %v",
+			len(params)-1, len(mu.uploaders), minMirrors, failures))
+	}
+	mu.mutex.Lock()
+	mu.warnings = append(mu.warnings, failures...)
+	mu.mutex.Unlock()
+
+	// id is params of mirror downloader since they can't be derived from
+	// anything else
+	return packDownloaderParams(downloaders.MirrorDownloaderName, params), nil
+}
@@ -2,7 +2,6 @@ package uploaders
 
 import (
 	"errors"
-	"fmt"
 
 	"io/ioutil"
 
@@ -12,12 +11,23 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
 	"github.com/takahawk/shadownet/logger"
 )
 
 // PastebinUploaderName is pastebin uploader component name
 const PastebinUploaderName = "pastebin"
 
+// PastebinUploaderSchema describes pastebin uploader and its params
+var PastebinUploaderSchema = common.ComponentSchema{
+	Name:        PastebinUploaderName,
+	Description: "Uploads data to pastebin.com",
+	Params: []common.ParamSchema{
+		{Name: "devKey", Type: common.ParamTypeString, Description: "pastebin developer key", Secret: true},
+	},
+}
+
 // TODO: mb should use N?
 // MaximumPastebinExpireTime is maximum value for `api_paste_expire_date` and
 // it means 1 year. Other possible values are: N (never), 10M (10 minutes),
@@ -41,10 +51,6 @@ const (
 	PastebinPrivacyPrivate = "2"
 )
 
-// PastebinRawPrefix is prefix for URL used to get saved paste in raw
-// (e.g. https://pastebin.com/raw/y1FKvrXe)
-const PastebinRawPrefix = "https://pastebin.com/raw"
-
 var successfulResponsePattern = regexp.MustCompile(`https://pastebin.com/(.+)$`)
 
 type pastebinUploader struct {
@@ -56,6 +62,7 @@ type pastebinUploader struct {
 // given API key that will be used to make upload requests
 func NewPastebinUploader(logger logger.Logger, apiKey string) Uploader {
 	return &pastebinUploader{
+		logger: logger,
 		apiKey: apiKey,
 	}
 }
@@ -80,12 +87,23 @@ func (pu *pastebinUploader) Name() string {
 	return PastebinUploaderName
 }
 
+// Schema returns schema of pastebin uploader. It is always PastebinUploaderSchema
+func (pu *pastebinUploader) Schema() common.ComponentSchema {
+	return PastebinUploaderSchema
+}
+
 // Params returns API key packed into byte array
 func (pu *pastebinUploader) Params() [][]byte {
 	return [][]byte{[]byte(pu.apiKey)}
 }
 
-// Upload saves data in byte array as a paste on Pastebin
+// DownloaderFor returns pastebin downloader with paste key as the only param
+func (pu *pastebinUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.PastebinDownloaderName, [][]byte{[]byte(id)}
+}
+
+// Upload saves data in byte array as a paste on Pastebin returning paste key
+// as id
 func (pu *pastebinUploader) Upload(content []byte) (id string, err error) {
 	// TODO: should check if this is possible to upload binary data
 	// TODO: implement
@@ -131,7 +149,5 @@ func (pu *pastebinUploader) Upload(content []byte) (id string, err error) {
 		return "", errors.New("failed to capture id")
 	}
 
-	id = fmt.Sprintf("%s/%s", PastebinRawPrefix, groups[1])
-
-	return id, nil
+	return groups[1], nil
 }
@@ -0,0 +1,207 @@
+package uploaders
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// S3UploaderName is s3 uploader component name
+const S3UploaderName = "s3"
+
+// S3UploaderSchema describes S3 uploader and its params
+var S3UploaderSchema = common.ComponentSchema{
+	Name:        S3UploaderName,
+	Description: "Uploads object to S3-compatible storage",
+	Params: []common.ParamSchema{
+		{Name: "endpoint", Type: common.ParamTypeString, Description: "endpoint URL"},
+		{Name: "bucket", Type: common.ParamTypeString, Description: "bucket name"},
+		{Name: "region", Type: common.ParamTypeString, Description: "region"},
+		{Name: "accessKey", Type: common.ParamTypeString, Description: "access key id", Secret: true},
+		{Name: "secretKey", Type: common.ParamTypeString, Description: "secret access key", Secret: true},
+	},
+}
+
+const (
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+	s3AmzDateFormat    = "20060102T150405Z"
+	s3ShortDateFormat  = "20060102"
+)
+
+type s3Uploader struct {
+	logger    logger.Logger
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+// NewS3Uploader returns uploader that puts data as objects with random keys
+// into a bucket of S3-compatible storage (AWS, MinIO, Ceph RGW, Garage etc.).
+// Requests are signed with AWS Signature Version 4 and path-style URLs are
+// used, so endpoint is just a base URL of storage. Credentials are not
+// exposed in ShadowNet URL, so bucket should allow anonymous reads for
+// objects to be downloaded
+func NewS3Uploader(logger logger.Logger, endpoint, bucket, region, accessKey, secretKey string) (Uploader, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New(fmt.Sprintf("invalid S3 endpoint: %s", endpoint))
+	}
+	if bucket == "" {
+		return nil, errors.New("S3 bucket should not be empty")
+	}
+	return &s3Uploader{
+		logger:    logger,
+		endpoint:  endpoint,
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+	}, nil
+}
+
+// NewS3UploaderWithParams is convenience function that calls NewS3Uploader.
+// It does expect 5 params: endpoint, bucket, region, access key and secret key
+func NewS3UploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	if len(params) != 5 {
+		return nil, errors.New("there should be 5 params: endpoint, bucket, region, access key and secret key")
+	}
+	return NewS3Uploader(logger, string(params[0]), string(params[1]), string(params[2]), string(params[3]), string(params[4]))
+}
+
+// Name returns s3 uploader name. It is always S3UploaderName
+func (su *s3Uploader) Name() string {
+	return S3UploaderName
+}
+
+// Schema returns schema of S3 uploader. It is always S3UploaderSchema
+func (su *s3Uploader) Schema() common.ComponentSchema {
+	return S3UploaderSchema
+}
+
+// Params returns endpoint, bucket, region and credentials packed into byte
+// arrays
+func (su *s3Uploader) Params() [][]byte {
+	return [][]byte{
+		[]byte(su.endpoint),
+		[]byte(su.bucket),
+		[]byte(su.region),
+		[]byte(su.accessKey),
+		[]byte(su.secretKey),
+	}
+}
+
+// DownloaderFor returns s3 downloader with endpoint, bucket and object key as
+// params
+func (su *s3Uploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.S3DownloaderName, [][]byte{[]byte(su.endpoint), []byte(su.bucket), []byte(id)}
+}
+
+// Upload puts data as a new object and returns its key as id
+func (su *s3Uploader) Upload(content []byte) (id string, err error) {
+	id = generateRandomFilename()
+	objectUrl, err := downloaders.S3ObjectURL(su.endpoint, su.bucket, id)
+	if err != nil {
+		su.logger.Errorf("%+v", err)
+		return "", err
+	}
+
+	su.logger.Infof("Uploading data to S3: %s", objectUrl)
+	r, err := http.NewRequest(http.MethodPut, objectUrl, bytes.NewReader(content))
+	if err != nil {
+		su.logger.Errorf("%+v", err)
+		return "", err
+	}
+	r.Header.Set("Content-Type", "application/octet-stream")
+	payloadHash := sha256.Sum256(content)
+	su.sign(r, hex.EncodeToString(payloadHash[:]), time.Now().UTC())
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		su.logger.Errorf("%+v", err)
+		return "", err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(rsp.Body)
+		su.logger.Errorf("Request failed with status code: %d", rsp.StatusCode)
+		su.logger.Errorf(string(body))
+		return "", errors.New(fmt.Sprintf("request failed with status code: %d", rsp.StatusCode))
+	}
+
+	su.logger.Infof("Success uploading data to S3. Key: %s", id)
+	return id, nil
+}
+
+// sign adds headers required by AWS Signature Version 4 to request
+func (su *s3Uploader) sign(r *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format(s3AmzDateFormat)
+	shortDate := now.Format(s3ShortDateFormat)
+	r.Header.Set("X-Amz-Date", amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": r.URL.Host}
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := strings.Join([]string{shortDate, su.region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		s3SigningAlgorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + su.secretKey)
+	for _, part := range []string{shortDate, su.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm, su.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
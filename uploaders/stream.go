@@ -0,0 +1,30 @@
+package uploaders
+
+import (
+	"io"
+)
+
+type bufferedStreamUploader struct {
+	Uploader
+}
+
+// NewStreamUploader returns StreamUploader for a given uploader. If uploader
+// doesn't support streaming by itself, the returned one reads the whole
+// content into memory and then calls Upload
+func NewStreamUploader(uploader Uploader) StreamUploader {
+	if su, ok := uploader.(StreamUploader); ok {
+		return su
+	}
+	return &bufferedStreamUploader{
+		Uploader: uploader,
+	}
+}
+
+// UploadStream reads content until EOF and uploads it
+func (bsu *bufferedStreamUploader) UploadStream(content io.Reader) (id string, err error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	return bsu.Upload(data)
+}
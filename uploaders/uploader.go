@@ -1,6 +1,8 @@
 package uploaders
 
 import (
+	"io"
+
 	"github.com/takahawk/shadownet/common"
 )
 
@@ -10,4 +12,16 @@ type Uploader interface {
 	// Upload uploads data in a byte array to storage returning id which can
 	// be used by corresponding Downloader to get that data
 	Upload(content []byte) (id string, err error)
+	// DownloaderFor returns name and parameters of Downloader component which
+	// is able to fetch content uploaded by this uploader with a given id
+	DownloaderFor(id string) (name string, params [][]byte)
+}
+
+// StreamUploader is Uploader that is able to upload data as a stream without
+// holding the whole content in memory
+type StreamUploader interface {
+	Uploader
+	// UploadStream uploads data read from content until EOF returning id
+	// which can be used by corresponding Downloader to get that data
+	UploadStream(content io.Reader) (id string, err error)
 }
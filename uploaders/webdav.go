@@ -0,0 +1,156 @@
+package uploaders
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/takahawk/shadownet/common"
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/logger"
+)
+
+// WebDAVUploaderName is webdav uploader component name
+const WebDAVUploaderName = "webdav"
+
+// WebDAVUploaderSchema describes WebDAV uploader and its params
+var WebDAVUploaderSchema = common.ComponentSchema{
+	Name:        WebDAVUploaderName,
+	Description: "Uploads file to WebDAV server. Collection should allow anonymous reads, since auth is not put into URL",
+	Params: []common.ParamSchema{
+		{Name: "url", Type: common.ParamTypeString, Description: "base URL"},
+		{Name: "user", Type: common.ParamTypeString, Description: "bearer token or user name, used only for upload", Secret: true, Optional: true},
+		{Name: "password", Type: common.ParamTypeString, Description: "password, used only for upload", Secret: true, Optional: true},
+	},
+}
+
+type webdavUploader struct {
+	logger  logger.Logger
+	baseUrl string
+	auth    [][]byte
+}
+
+// NewWebDAVUploader returns uploader that puts data as files with random
+// names under a given collection of WebDAV server (Nextcloud, ownCloud,
+// Apache mod_dav etc.). Files are spread among subcollections named by first
+// 2 symbols of file name which are created with MKCOL as needed. Auth is
+// optional: none, bearer token or user and password for basic auth. It is
+// used only for upload and is never put into ShadowNet URL, so collection
+// should allow anonymous reads (i.e. public share of Nextcloud or ownCloud),
+// otherwise download fails with 401
+func NewWebDAVUploader(logger logger.Logger, baseUrl string, auth ...[]byte) (Uploader, error) {
+	if err := downloaders.ValidateWebDAVURL(baseUrl); err != nil {
+		return nil, err
+	}
+	if _, err := downloaders.WebDAVAuthorization(auth...); err != nil {
+		return nil, err
+	}
+	return &webdavUploader{
+		logger:  logger,
+		baseUrl: baseUrl,
+		auth:    auth,
+	}, nil
+}
+
+// NewWebDAVUploaderWithParams is convenience function that calls
+// NewWebDAVUploader. The first param is base URL, the rest are auth params
+func NewWebDAVUploaderWithParams(logger logger.Logger, params ...[]byte) (Uploader, error) {
+	if len(params) == 0 {
+		return nil, errors.New("there should be at least 1 param: base URL")
+	}
+	return NewWebDAVUploader(logger, string(params[0]), params[1:]...)
+}
+
+// Name returns webdav uploader name. It is always WebDAVUploaderName
+func (wu *webdavUploader) Name() string {
+	return WebDAVUploaderName
+}
+
+// Schema returns schema of WebDAV uploader. It is always WebDAVUploaderSchema
+func (wu *webdavUploader) Schema() common.ComponentSchema {
+	return WebDAVUploaderSchema
+}
+
+// Params returns base URL followed by auth params
+func (wu *webdavUploader) Params() [][]byte {
+	return append([][]byte{[]byte(wu.baseUrl)}, wu.auth...)
+}
+
+// DownloaderFor returns webdav downloader with file URL as the only param.
+// Auth is left out, so that credentials don't get into public URL
+func (wu *webdavUploader) DownloaderFor(id string) (name string, params [][]byte) {
+	return downloaders.WebDAVDownloaderName, [][]byte{[]byte(id)}
+}
+
+// Upload puts data as a new file and returns its URL
+func (wu *webdavUploader) Upload(content []byte) (id string, err error) {
+	return wu.UploadStream(bytes.NewReader(content))
+}
+
+// UploadStream puts content as a new file while it is being read and returns
+// its URL
+func (wu *webdavUploader) UploadStream(content io.Reader) (id string, err error) {
+	// base URL is already validated during creation
+	base, _ := url.Parse(wu.baseUrl)
+	filename := generateRandomFilename()
+	collection := base.JoinPath(filename[:2])
+
+	err = wu.makeCollection(collection.String() + "/")
+	if err != nil {
+		// parent collection may not exist as well
+		err = wu.makeCollection(base.JoinPath("/").String())
+		if err == nil {
+			err = wu.makeCollection(collection.String() + "/")
+		}
+	}
+	if err != nil {
+		wu.logger.Errorf("%+v", err)
+		return "", err
+	}
+
+	fileUrl := collection.JoinPath(filename).String()
+	wu.logger.Infof("Uploading data to WebDAV: %s", fileUrl)
+	rsp, err := wu.do(http.MethodPut, fileUrl, content)
+	if err != nil {
+		wu.logger.Errorf("%+v", err)
+		return "", err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusCreated && rsp.StatusCode != http.StatusNoContent && rsp.StatusCode != http.StatusOK {
+		wu.logger.Errorf("Request failed with status code: %d", rsp.StatusCode)
+		return "", errors.New(fmt.Sprintf("request failed with status code: %d", rsp.StatusCode))
+	}
+
+	wu.logger.Infof("Success uploading data to WebDAV")
+	return fileUrl, nil
+}
+
+// makeCollection creates collection if it doesn't exist yet
+func (wu *webdavUploader) makeCollection(collectionUrl string) error {
+	rsp, err := wu.do("MKCOL", collectionUrl, nil)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	// 405 means that there is already something by this URL
+	if rsp.StatusCode != http.StatusCreated && rsp.StatusCode != http.StatusMethodNotAllowed {
+		return errors.New(fmt.Sprintf("failed to create collection %s, status code: %d", collectionUrl, rsp.StatusCode))
+	}
+	return nil
+}
+
+func (wu *webdavUploader) do(method string, target string, body io.Reader) (*http.Response, error) {
+	r, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	// auth is already validated during creation
+	authorization, _ := downloaders.WebDAVAuthorization(wu.auth...)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	return http.DefaultClient.Do(r)
+}
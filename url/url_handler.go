@@ -7,10 +7,15 @@ import (
 const DownloaderURLPrefix = "down"
 const TransformerURLPrefix = "trans"
 
+// URLVersion2Prefix is prefix of ShadowNet URLs in v2 format. URLs without
+// it are considered to be in legacy format
+const URLVersion2Prefix = "v2-"
+
 // Tool to handle ShadowNet URLs
 type UrlHandler interface {
 	// Use ID and upload components to make ShadowNet URL
 	MakeURL(id string, components... common.Component) (string, error)
-	// Parse URL to get download components
+	// Parse URL to get download components. Both v2 and legacy URLs are
+	// accepted
 	GetDownloadComponents(url string) ([]common.Component, error)
-}
\ No newline at end of file
+}
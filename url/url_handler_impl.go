@@ -21,47 +21,95 @@ type urlHandler struct {
 
 var urlPartPattern = regexp.MustCompile(`(trans|down)_(.+):(.*)`)
 
-func NewUrlHandler(logger logger.Logger) UrlHandler {
-	resolver := resolvers.NewBuiltinResolver(logger)
+// NewUrlHandler returns URL handler that resolves download components with a
+// given resolver
+func NewUrlHandler(logger logger.Logger, resolver resolvers.Resolver) UrlHandler {
 	return &urlHandler{
 		logger:   logger,
 		resolver: resolver,
 	}
 }
 
+// MakeURL returns ShadowNet URL in v2 format
 func (uh *urlHandler) MakeURL(id string, components ...common.Component) (string, error) {
-	var urlParts []string
+	var urlParts []urlPart
 
 	for _, component := range components {
 		switch component := component.(type) {
 		case transformers.Transformer:
-			urlParts = append(urlParts, getURLPart(component, component.Params()...))
+			urlParts = append(urlParts, urlPart{
+				prefix: TransformerURLPrefix,
+				name:   component.Name(),
+				params: component.Params(),
+			})
 		case uploaders.Uploader:
 			// mb double-check for uploader to be only the last component?
-			urlParts = append(urlParts, getURLPart(component, []byte(id)))
+			name, params := component.DownloaderFor(id)
+			if name == "" {
+				return "", errors.New(fmt.Sprintf("uploader %s hasn't provided downloader for uploaded data", component.Name()))
+			}
+			urlParts = append(urlParts, urlPart{
+				prefix: DownloaderURLPrefix,
+				name:   name,
+				params: params,
+			})
 		}
 	}
 
-	var sb strings.Builder
-	for i := len(urlParts) - 1; i > 0; i-- {
-		sb.WriteString(urlParts[i])
-		sb.WriteString(".")
+	// download components go in reverse order: downloader first and then
+	// transformers from the last applied to the first one
+	for i, j := 0, len(urlParts)-1; i < j; i, j = i+1, j-1 {
+		urlParts[i], urlParts[j] = urlParts[j], urlParts[i]
 	}
-	sb.WriteString(urlParts[0])
-	return sb.String(), nil
+
+	return encodeURLv2(urlParts)
 }
 
 func (uh *urlHandler) GetDownloadComponents(url string) ([]common.Component, error) {
+	var urlParts []urlPart
+	var err error
+	if strings.HasPrefix(url, URLVersion2Prefix) {
+		urlParts, err = decodeURLv2(url)
+	} else {
+		urlParts, err = parseLegacyURL(url)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	components := make([]common.Component, 0)
-	for _, urlPart := range strings.Split(url, ".") {
-		urlPart, err := base64.StdEncoding.DecodeString(urlPart)
+	for _, urlPart := range urlParts {
+		var component common.Component
+		switch urlPart.prefix {
+		case DownloaderURLPrefix:
+			component, err = uh.resolver.ResolveDownloader(urlPart.name, urlPart.params...)
+		case TransformerURLPrefix:
+			component, err = uh.resolver.ResolveTransformer(urlPart.name, urlPart.params...)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+// parseLegacyURL parses URL consisting of dot separated base64'd parts of
+// the following format:
+// [Type]_[ID]:[Base64dCommaSeparatedParameters]
+func parseLegacyURL(url string) ([]urlPart, error) {
+	var urlParts []urlPart
+	for _, rawPart := range strings.Split(url, ".") {
+		decodedPart, err := base64.StdEncoding.DecodeString(rawPart)
 		if err != nil {
 			return nil, err
 		}
 
-		groups := urlPartPattern.FindStringSubmatch(string(urlPart))
+		groups := urlPartPattern.FindStringSubmatch(string(decodedPart))
 		if len(groups) != 4 {
-			return nil, errors.New(fmt.Sprintf("invalid url part: %s", urlPart))
+			return nil, errors.New(fmt.Sprintf("invalid url part: %s", decodedPart))
 		}
 		prefix := groups[1]
 		name := groups[2]
@@ -78,42 +126,11 @@ func (uh *urlHandler) GetDownloadComponents(url string) ([]common.Component, err
 			}
 		}
 
-		var component common.Component
-		switch prefix {
-		case DownloaderURLPrefix:
-			component, err = uh.resolver.ResolveDownloader(name, params...)
-		case TransformerURLPrefix:
-			component, err = uh.resolver.ResolveTransformer(name, params...)
-		}
-
-		if err != nil {
-			return nil, err
-		}
-
-		components = append(components, component)
-
-	}
-	return components, nil
-}
-
-// [Type]_[ID]:[Base64dCommaSeparatedParameters]
-func getURLPart(component common.Component, params ...[]byte) string {
-	var sb strings.Builder
-
-	switch component.(type) {
-	case transformers.Transformer:
-		sb.WriteString(TransformerURLPrefix)
-	case uploaders.Uploader:
-		sb.WriteString(DownloaderURLPrefix)
-	}
-	sb.WriteString("_")
-	sb.WriteString(component.Name())
-	sb.WriteString(":")
-	for i, param := range params {
-		sb.WriteString(base64.StdEncoding.EncodeToString(param))
-		if i != len(params)-1 {
-			sb.WriteString(",")
-		}
+		urlParts = append(urlParts, urlPart{
+			prefix: prefix,
+			name:   name,
+			params: params,
+		})
 	}
-	return base64.StdEncoding.EncodeToString([]byte(sb.String()))
+	return urlParts, nil
 }
@@ -0,0 +1,221 @@
+package url
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/takahawk/shadownet/downloaders"
+	"github.com/takahawk/shadownet/resolvers"
+	"github.com/takahawk/shadownet/transformers"
+)
+
+// v2 URL is URLVersion2Prefix followed by base64url (without padding) encoded
+// header byte and body. Body is a sequence of components: downloader first
+// and then transformers in order they should be applied during download.
+// Every component is written as:
+//   [uvarint component id]([uvarint name length][name] if id is 0)
+//   [uvarint params count]([uvarint param length][param])*
+// If urlFlagCompressed is set in header, body is compressed with DEFLATE.
+
+const (
+	// urlFlagCompressed is set in v2 URL header if body is DEFLATE compressed
+	urlFlagCompressed = 1 << iota
+)
+
+// componentIDs maps index to the name of well-known component, so that its
+// name takes only one byte in v2 URL. Id 0 means that the name is written
+// explicitly. Ids must never be changed, new components should be appended
+var componentIDs = []string{
+	"",
+	downloaders.WebDownloaderName,
+	downloaders.PastebinDownloaderName,
+	downloaders.DropboxDownloaderName,
+	transformers.Base64TransformerName,
+	transformers.AESEncryptorName,
+	transformers.AESGCMEncryptorName,
+	transformers.PassphraseEncryptorName,
+	transformers.X25519EncryptorName,
+	transformers.Ed25519SignerName,
+	transformers.GzipCompressorName,
+	transformers.ZlibCompressorName,
+	transformers.FlateCompressorName,
+	transformers.AutoCompressorName,
+	downloaders.ChunkedDownloaderName,
+	downloaders.ErasureDownloaderName,
+	downloaders.MirrorDownloaderName,
+	downloaders.FileDownloaderName,
+	downloaders.S3DownloaderName,
+	downloaders.WebDAVDownloaderName,
+	downloaders.GistDownloaderName,
+	downloaders.GDriveDownloaderName,
+	downloaders.IPFSDownloaderName,
+	downloaders.DataDownloaderName,
+	resolvers.WasmTransformerName,
+}
+
+// maxURLParamLength limits length of names and params during decoding, so
+// that malformed URL can't make us allocate a lot of memory
+const maxURLParamLength = 1 << 16
+
+// maxURLBodyLength limits length of decompressed body of v2 URL, so that
+// short URL can't expand into a lot of data
+const maxURLBodyLength = 4 << 20
+
+// maxURLParts limits number of components in v2 URL
+const maxURLParts = 64
+
+// urlPart is single component of ShadowNet URL
+type urlPart struct {
+	prefix string
+	name   string
+	params [][]byte
+}
+
+func encodeURLv2(parts []urlPart) (string, error) {
+	var body bytes.Buffer
+	for _, part := range parts {
+		id := componentID(part.name)
+		body.Write(binary.AppendUvarint(nil, uint64(id)))
+		if id == 0 {
+			writeLengthPrefixed(&body, []byte(part.name))
+		}
+		body.Write(binary.AppendUvarint(nil, uint64(len(part.params))))
+		for _, param := range part.params {
+			writeLengthPrefixed(&body, param)
+		}
+	}
+
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	fw.Write(body.Bytes())
+	err = fw.Close()
+	if err != nil {
+		return "", err
+	}
+
+	var header byte
+	payload := body.Bytes()
+	if compressed.Len() < body.Len() {
+		header |= urlFlagCompressed
+		payload = compressed.Bytes()
+	}
+
+	return URLVersion2Prefix + base64.RawURLEncoding.EncodeToString(append([]byte{header}, payload...)), nil
+}
+
+func decodeURLv2(url string) ([]urlPart, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(url, URLVersion2Prefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty v2 url")
+	}
+
+	header := data[0]
+	body := data[1:]
+	if header&urlFlagCompressed != 0 {
+		body, err = io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(body)), maxURLBodyLength+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxURLBodyLength {
+			return nil, errors.New(fmt.Sprintf("url body is longer than %d bytes", maxURLBodyLength))
+		}
+	}
+	r := bufio.NewReader(bytes.NewReader(body))
+
+	var parts []urlPart
+	for {
+		id, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == maxURLParts {
+			return nil, errors.New(fmt.Sprintf("there are more than %d components in url", maxURLParts))
+		}
+
+		var part urlPart
+		switch {
+		case id == 0:
+			name, err := readLengthPrefixed(r)
+			if err != nil {
+				return nil, err
+			}
+			part.name = string(name)
+		case id < uint64(len(componentIDs)):
+			part.name = componentIDs[id]
+		default:
+			return nil, errors.New(fmt.Sprintf("unknown component id in url: %d", id))
+		}
+
+		if len(parts) == 0 {
+			part.prefix = DownloaderURLPrefix
+		} else {
+			part.prefix = TransformerURLPrefix
+		}
+
+		count, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < count; i++ {
+			param, err := readLengthPrefixed(r)
+			if err != nil {
+				return nil, err
+			}
+			part.params = append(part.params, param)
+		}
+
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return nil, errors.New("there are no components in url")
+	}
+
+	return parts, nil
+}
+
+func componentID(name string) int {
+	for id, componentName := range componentIDs {
+		if id != 0 && componentName == name {
+			return id
+		}
+	}
+	return 0
+}
+
+func writeLengthPrefixed(w *bytes.Buffer, data []byte) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	w.Write(data)
+}
+
+func readLengthPrefixed(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxURLParamLength {
+		return nil, errors.New(fmt.Sprintf("too long url param: %d bytes", length))
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}